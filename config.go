@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// configKey is a setting of a config file and the flag it stands in for.
+type configKey struct {
+	name string
+	flag string
+
+	//REPEATABLE FLAGS ARE SET ONCE PER ITEM, join FLAGS TAKE A LIST AS ONE COMMA SEPARATED VALUE
+	list bool
+	join bool
+
+	//CATCHES BAD VALUES WHILE THEIR LINE IS STILL KNOWN
+	check func(value string) error
+}
+
+// configKeys are every setting a config file may hold. Each one can also be
+// given as a SCANNER_* environment variable, see configEnv.
+var configKeys = []configKey{
+	{name: "include", flag: "include", list: true, check: checkPattern},
+	{name: "exclude", flag: "exclude", list: true, check: checkPattern},
+	{name: "workers", flag: "workers"},
+	{name: "max_size", flag: "max-size"},
+	{name: "archive_depth", flag: "archive-depth"},
+	{name: "ignore_files", flag: "ignore-files"},
+	{name: "cache", flag: "cache"},
+	{name: "mode", flag: "mode", check: oneOf("local", "coordinator", "agent")},
+	{name: "type_by", flag: "type-by", check: oneOf(scanner.TypeByExtension, scanner.TypeByMime)},
+	{name: "prefilter", flag: "prefilter"},
+	{name: "hash", flag: "hash", join: true, check: checkHash},
+	{name: "secrets", flag: "secrets"},
+	{name: "secret_rules", flag: "secret-rules"},
+	{name: "denylists", flag: "denylist", list: true},
+	{name: "manifest", flag: "manifest"},
+	{name: "write_manifest", flag: "write-manifest"},
+	{name: "checkpoint", flag: "checkpoint"},
+	{name: "checkpoint_interval", flag: "checkpoint-interval"},
+	{name: "verify", flag: "verify"},
+	{name: "top", flag: "top"},
+	{name: "dir_duplicates", flag: "dir-duplicates"},
+	{name: "similarity", flag: "similarity"},
+	{name: "outputs", flag: "output", list: true},
+	{name: "results", flag: "results"},
+	{name: "http.addr", flag: "http"},
+	{name: "coordinator.addr", flag: "coordinator-addr"},
+	{name: "coordinator.url", flag: "coordinator"},
+	{name: "agent.id", flag: "agent-id"},
+	{name: "agent.batch", flag: "batch"},
+}
+
+// configRoot is a directory of the roots list with its own patterns.
+type configRoot struct {
+	path  string
+	rules scanner.RootRules
+}
+
+// configSetting is a value of the config file for one flag, with its line.
+type configSetting struct {
+	key   configKey
+	value string
+	line  int
+}
+
+// configFile is a loaded and checked config file.
+type configFile struct {
+	settings []configSetting
+	roots    []configRoot
+}
+
+// loadConfigFile reads the config file at path and checks every setting in
+// it. It returns every problem found, each with its line.
+func loadConfigFile(path string) (*configFile, []error) {
+	root, err := parseConfigFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	file := &configFile{}
+	var errs []error
+	fail := func(line int, err error) {
+		errs = append(errs, &configError{path: path, line: line, err: err})
+	}
+
+	var walk func(table *configNode, prefix string)
+	walk = func(table *configNode, prefix string) {
+		for _, name := range table.keys {
+			node := table.fields[name]
+			name = prefix + name
+
+			if name == "roots" {
+				file.roots = append(file.roots, configRoots(node, fail)...)
+				continue
+			}
+
+			i := slices.IndexFunc(configKeys, func(key configKey) bool { return key.name == name })
+			if i < 0 {
+				if node.kind == tableNode && slices.ContainsFunc(configKeys, func(key configKey) bool {
+					return strings.HasPrefix(key.name, name+".")
+				}) {
+					walk(node, name+".")
+					continue
+				}
+				fail(node.line, fmt.Errorf("unknown setting %q", name))
+				continue
+			}
+
+			key := configKeys[i]
+			for _, value := range configValues(key, node, fail) {
+				if key.check != nil {
+					if err := key.check(value.value); err != nil {
+						fail(value.line, fmt.Errorf("%s: %v", name, err))
+						continue
+					}
+				}
+				file.settings = append(file.settings, value)
+			}
+		}
+	}
+	walk(root, "")
+
+	return file, errs
+}
+
+// configValues returns the values node gives key.
+func configValues(key configKey, node *configNode, fail func(int, error)) []configSetting {
+	switch {
+	case node.kind == scalarNode:
+		return []configSetting{{key: key, value: node.value, line: node.line}}
+
+	case node.kind == listNode && (key.list || key.join):
+		var settings []configSetting
+		var joined []string
+		for _, item := range node.items {
+			if item.kind != scalarNode {
+				fail(item.line, fmt.Errorf("%s: expected a list of values", key.name))
+				return nil
+			}
+			settings = append(settings, configSetting{key: key, value: item.value, line: item.line})
+			joined = append(joined, item.value)
+		}
+		if key.join {
+			return []configSetting{{key: key, value: strings.Join(joined, ","), line: node.line}}
+		}
+		return settings
+	}
+
+	if key.list {
+		fail(node.line, fmt.Errorf("%s: expected a value or a list of values", key.name))
+	} else {
+		fail(node.line, fmt.Errorf("%s: expected a single value", key.name))
+	}
+	return nil
+}
+
+// configRoots reads the roots list, whose items are either a path or a table
+// with a path and the include and exclude patterns of that path.
+func configRoots(node *configNode, fail func(int, error)) []configRoot {
+	if node.kind != listNode {
+		fail(node.line, errors.New("roots: expected a list of directories"))
+		return nil
+	}
+
+	var roots []configRoot
+	for _, item := range node.items {
+		if item.kind == scalarNode && item.value != "" {
+			roots = append(roots, configRoot{path: item.value})
+			continue
+		}
+		if item.kind != tableNode {
+			fail(item.line, errors.New("roots: expected a directory or a table with a path"))
+			continue
+		}
+
+		var root configRoot
+		for _, name := range item.keys {
+			field := item.fields[name]
+			switch name {
+			case "path":
+				if field.kind != scalarNode || field.value == "" {
+					fail(field.line, errors.New("roots: path must be a directory"))
+					continue
+				}
+				root.path = field.value
+			case "include", "exclude":
+				key := configKey{name: "roots." + name, list: true}
+				for _, value := range configValues(key, field, fail) {
+					if err := checkPattern(value.value); err != nil {
+						fail(value.line, fmt.Errorf("%s: %v", key.name, err))
+					} else if name == "include" {
+						root.rules.Include = append(root.rules.Include, value.value)
+					} else {
+						root.rules.Exclude = append(root.rules.Exclude, value.value)
+					}
+				}
+			default:
+				fail(field.line, fmt.Errorf("unknown root setting %q", name))
+			}
+		}
+		if root.path == "" {
+			fail(item.line, errors.New("roots: missing path"))
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// configEnv is the environment variable of a config key: http.addr is read
+// from SCANNER_HTTP_ADDR. Lists are comma separated.
+func configEnv(name string) string {
+	return "SCANNER_" + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// applyConfig fills in the flags that were not given on the command line,
+// first from SCANNER_* environment variables and then from the -config file,
+// or the file SCANNER_CONFIG names. It returns every problem found.
+func (f *scanFlags) applyConfig(flags *flag.FlagSet) []error {
+	given := make(map[string]bool)
+	flags.Visit(func(fl *flag.Flag) {
+		given[fl.Name] = true
+	})
+
+	var errs []error
+	for _, key := range configKeys {
+		env := configEnv(key.name)
+		value, ok := os.LookupEnv(env)
+		if !ok || given[key.flag] || flags.Lookup(key.flag) == nil {
+			continue
+		}
+
+		values := []string{value}
+		if key.list {
+			values = splitList(value)
+		}
+		for _, value := range values {
+			err := setFlag(flags, key, value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", env, err))
+			}
+		}
+		given[key.flag] = true
+	}
+	if value, ok := os.LookupEnv("SCANNER_ROOTS"); ok && !given["dir"] {
+		f.dirs = splitList(value)
+		given["dir"] = true
+	}
+
+	path := f.configPath
+	if path == "" {
+		path = os.Getenv("SCANNER_CONFIG")
+	}
+	if path == "" {
+		return errs
+	}
+	file, fileErrs := loadConfigFile(path)
+	if len(fileErrs) > 0 {
+		return append(errs, fileErrs...)
+	}
+	return append(errs, f.applyConfigFile(flags, path, file, given)...)
+}
+
+// applyConfigFile sets every flag not in given from file.
+func (f *scanFlags) applyConfigFile(flags *flag.FlagSet, path string, file *configFile, given map[string]bool) []error {
+	var errs []error
+	for _, setting := range file.settings {
+		if given[setting.key.flag] || flags.Lookup(setting.key.flag) == nil {
+			continue
+		}
+		if err := setFlag(flags, setting.key, setting.value); err != nil {
+			errs = append(errs, &configError{path: path, line: setting.line, err: fmt.Errorf("%s: %v", setting.key.name, err)})
+		}
+	}
+
+	//THE PATTERNS OF A ROOT STILL APPLY WHEN -dir NAMES IT
+	for _, root := range file.roots {
+		if !given["dir"] {
+			f.dirs = append(f.dirs, root.path)
+		}
+		if len(root.rules.Include)+len(root.rules.Exclude) > 0 {
+			if f.rootRules == nil {
+				f.rootRules = make(map[string]scanner.RootRules)
+			}
+			f.rootRules[root.path] = root.rules
+		}
+	}
+	return errs
+}
+
+// setFlag sets the flag of key to value, checking it first.
+func setFlag(flags *flag.FlagSet, key configKey, value string) error {
+	if key.check != nil {
+		if err := key.check(value); err != nil {
+			return err
+		}
+	}
+	if err := flags.Set(key.flag, value); err != nil {
+		return fmt.Errorf("invalid value %q: %v", value, err)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func checkPattern(pattern string) error {
+	return scanner.ValidatePatterns([]string{pattern})
+}
+
+func checkHash(value string) error {
+	_, err := scanner.ParseHashAlgorithms(value)
+	return err
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("must be one of %s, not %q", strings.Join(values, ", "), value)
+		}
+		return nil
+	}
+}
+
+// runConfig implements "config validate FILE" and returns the exit code.
+func runConfig(args []string) int {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s config validate FILE\n\nCheck a .json, .yaml, .yml or .toml config file and list every problem with its line.\n", os.Args[0])
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 2 || flags.Arg(0) != "validate" {
+		flags.Usage()
+		return exitUsage
+	}
+	path := flags.Arg(1)
+
+	file, errs := loadConfigFile(path)
+	if file != nil {
+		//SETTING THE FLAGS OF A SCAN CATCHES VALUES OF THE WRONG TYPE
+		scanFlagSet := flag.NewFlagSet("scan", flag.ContinueOnError)
+		var f scanFlags
+		f.register(scanFlagSet)
+		errs = append(errs, f.applyConfigFile(scanFlagSet, path, file, map[string]bool{})...)
+		slices.SortStableFunc(errs, func(a, b error) int {
+			return a.(*configError).line - b.(*configError).line
+		})
+	}
+
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		return exitUsage
+	}
+	fmt.Printf("%s is valid\n", path)
+	return exitOK
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfigFile tests that the three formats load the same settings
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"scan.yaml": `# artifacts
+roots:
+  - path: /srv/a
+    include: ["*.jar", "*.war"]
+  - /srv/b
+workers: 8
+hash:
+  - sha256
+  - md5
+http:
+  addr: ":9000"
+`,
+		"scan.toml": `workers = 8
+hash = ["sha256",
+  "md5"]
+
+[http]
+addr = ":9000" # api
+
+[[roots]]
+path = "/srv/a"
+include = ["*.jar", "*.war"]
+
+[[roots]]
+path = "/srv/b"
+`,
+		"scan.json": `{
+  "roots": [{"path": "/srv/a", "include": ["*.jar", "*.war"]}, "/srv/b"],
+  "workers": 8,
+  "hash": ["sha256", "md5"],
+  "http": {"addr": ":9000"}
+}`,
+	}
+
+	for name, content := range files {
+		file, errs := loadConfigFile(writeConfig(t, name, content))
+		if len(errs) > 0 {
+			t.Errorf("Expected %s to load, got %v", name, errs)
+			continue
+		}
+
+		settings := make(map[string]string)
+		for _, setting := range file.settings {
+			settings[setting.key.name] = setting.value
+		}
+		if settings["workers"] != "8" || settings["hash"] != "sha256,md5" || settings["http.addr"] != ":9000" {
+			t.Errorf("Expected workers, hash and http.addr from %s, got %v", name, settings)
+		}
+		if len(file.roots) != 2 || file.roots[0].path != "/srv/a" || !slices.Equal(file.roots[0].rules.Include, []string{"*.jar", "*.war"}) || file.roots[1].path != "/srv/b" {
+			t.Errorf("Expected two roots from %s, got %+v", name, file.roots)
+		}
+	}
+}
+
+// TestLoadConfigFile_Errors tests that every problem is reported with its line
+func TestLoadConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{"bad.yaml", "workers: 4\nmode: peer\nroots:\n  - path: /srv\n    incude: '*.go'\nhttp:\n  port: 80\n", []string{
+			`:2: mode: must be one of`, `:5: unknown root setting "incude"`, `:7: unknown setting "http.port"`,
+		}},
+		{"indent.yaml", "http:\n  addr: x\n    extra: y\n", []string{":3: mapping values are not allowed"}},
+		{"duplicate.yaml", "workers: 1\nworkers: 2\n", []string{`:2: duplicate key "workers"`}},
+		{"bad.toml", "workers = 4\n\n[http]\naddr = :9000\n", []string{":4: expected value"}},
+		{"table.toml", "[http]\naddr = \"x\"\n[http]\n", []string{":3: Key 'http' has already been defined"}},
+		{"empty.yaml", "roots:\n-\n- /srv\n", []string{":2: roots: expected a directory"}},
+		{"roots.toml", "[[roots]]\npath = '/srv/a'\n\n[[roots]]\npath = '/srv/b'\nincude = '*.go'\n", []string{`: unknown root setting "incude"`}},
+		{"bad.json", "{\n  \"workers\": 4,\n  \"hash\": [\"sha256\" \"md5\"]\n}\n", []string{":3: invalid character"}},
+		{"list.json", "{\n  \"workers\": [1, 2]\n}\n", []string{":2: workers: expected a single value"}},
+		{"config.ini", "workers = 4\n", []string{"unknown config format"}},
+	}
+
+	for _, tc := range tests {
+		_, errs := loadConfigFile(writeConfig(t, tc.name, tc.content))
+		if len(errs) != len(tc.expected) {
+			t.Errorf("Expected %d errors for %s, got %v", len(tc.expected), tc.name, errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.Contains(err.Error(), tc.expected[i]) {
+				t.Errorf("Expected %q in error of %s, got %q", tc.expected[i], tc.name, err)
+			}
+		}
+	}
+}
+
+// TestApplyConfig tests that flags override the environment and the
+// environment overrides the config file
+func TestApplyConfig(t *testing.T) {
+	path := writeConfig(t, "scan.yaml", `roots:
+  - path: /srv/a
+    exclude: [tmp]
+  - /srv/b
+workers: 8
+max_size: 1000
+type_by: mime
+include: ["*.go"]
+`)
+	t.Setenv("SCANNER_CONFIG", path)
+	t.Setenv("SCANNER_WORKERS", "2")
+	t.Setenv("SCANNER_MAX_SIZE", "5")
+	t.Setenv("SCANNER_INCLUDE", "*.jar, *.war")
+
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	var f scanFlags
+	f.register(flags)
+	if err := flags.Parse([]string{"-workers=16"}); err != nil {
+		t.Fatal(err)
+	}
+	if errs := f.applyConfig(flags); len(errs) > 0 {
+		t.Fatalf("Expected config to apply, got %v", errs)
+	}
+
+	if f.workers != 16 {
+		t.Errorf("Expected flag to win with 16 workers, got %d", f.workers)
+	}
+	if f.maxSize != 5 || !slices.Equal(f.include, []string{"*.jar", "*.war"}) {
+		t.Errorf("Expected environment to win with max size 5 and two includes, got %d and %v", f.maxSize, f.include)
+	}
+	if f.typeBy != "mime" {
+		t.Errorf("Expected type-by from the config file, got %s", f.typeBy)
+	}
+	if !slices.Equal(f.dirs, []string{"/srv/a", "/srv/b"}) || !slices.Equal(f.rootRules["/srv/a"].Exclude, []string{"tmp"}) {
+		t.Errorf("Expected roots from the config file, got %v and %v", f.dirs, f.rootRules)
+	}
+	if f.hash != "sha256" {
+		t.Errorf("Expected default hash to stay, got %s", f.hash)
+	}
+}
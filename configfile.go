@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type configNodeKind int
+
+const (
+	scalarNode configNodeKind = iota
+	listNode
+	tableNode
+)
+
+// configNode is a value of a config file with the line it starts on. Scalars
+// keep their text, the flag they end up in parses it.
+type configNode struct {
+	kind  configNodeKind
+	line  int
+	value string
+
+	items []*configNode
+
+	keys   []string
+	fields map[string]*configNode
+}
+
+func newTable(line int) *configNode {
+	return &configNode{kind: tableNode, line: line, fields: make(map[string]*configNode)}
+}
+
+func (n *configNode) set(key string, value *configNode) error {
+	if _, ok := n.fields[key]; ok {
+		return configLineError(value.line, fmt.Errorf("duplicate key %q", key))
+	}
+	n.keys = append(n.keys, key)
+	n.fields[key] = value
+	return nil
+}
+
+// configError is a problem on one line of a config file.
+type configError struct {
+	path string
+	line int
+	err  error
+}
+
+func (e *configError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("%s: %v", e.path, e.err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.path, e.line, e.err)
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+// configLineError is filled in with the path by parseConfigFile.
+func configLineError(line int, err error) error {
+	return &configError{line: line, err: err}
+}
+
+// parseConfigFile reads a JSON, YAML or TOML config file, picked by its
+// extension, into a table.
+func parseConfigFile(path string) (*configNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *configNode
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		root, err = parseJSONConfig(data)
+	case ".yaml", ".yml":
+		root, err = parseYAMLConfig(data)
+	case ".toml":
+		root, err = parseTOMLConfig(data)
+	default:
+		return nil, fmt.Errorf("%s: unknown config format %q, use .json, .yaml, .yml or .toml", path, ext)
+	}
+
+	var lineErr *configError
+	if errors.As(err, &lineErr) {
+		lineErr.path = path
+		return nil, lineErr
+	}
+	if err != nil {
+		return nil, &configError{path: path, err: err}
+	}
+	if root.kind != tableNode {
+		return nil, &configError{path: path, line: root.line, err: errors.New("expected a table of settings")}
+	}
+	return root, nil
+}
+
+// lineOf returns the 1-based line of offset in data.
+func lineOf(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func parseJSONConfig(data []byte) (*configNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	node, err := decodeJSONNode(decoder, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, configLineError(lineOf(data, decoder.InputOffset()), errors.New("unexpected content after the settings"))
+	}
+	return node, nil
+}
+
+func decodeJSONNode(decoder *json.Decoder, data []byte) (*configNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, jsonConfigError(data, decoder, err)
+	}
+	line := lineOf(data, decoder.InputOffset()-1)
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			node := &configNode{kind: listNode, line: line}
+			for decoder.More() {
+				item, err := decodeJSONNode(decoder, data)
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+			_, err := decoder.Token()
+			return node, jsonConfigError(data, decoder, err)
+		}
+
+		node := newTable(line)
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, jsonConfigError(data, decoder, err)
+			}
+			value, err := decodeJSONNode(decoder, data)
+			if err != nil {
+				return nil, err
+			}
+			if err := node.set(key.(string), value); err != nil {
+				return nil, err
+			}
+		}
+		_, err := decoder.Token()
+		return node, jsonConfigError(data, decoder, err)
+
+	case string:
+		return &configNode{line: line, value: token}, nil
+	case json.Number:
+		return &configNode{line: line, value: token.String()}, nil
+	case bool:
+		return &configNode{line: line, value: strconv.FormatBool(token)}, nil
+	default:
+		return nil, configLineError(line, errors.New("null is not a setting value"))
+	}
+}
+
+// jsonConfigError puts the line of a JSON syntax error in front of it.
+func jsonConfigError(data []byte, decoder *json.Decoder, err error) error {
+	if err == nil {
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return configLineError(lineOf(data, syntaxErr.Offset), err)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return configLineError(lineOf(data, int64(len(data))), errors.New("unexpected end of file"))
+	}
+	return configLineError(lineOf(data, decoder.InputOffset()), err)
+}
+
+func parseYAMLConfig(data []byte) (*configNode, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, yamlConfigError(err)
+	}
+	if len(document.Content) == 0 {
+		return newTable(1), nil
+	}
+	return yamlNode(document.Content[0])
+}
+
+func yamlNode(node *yaml.Node) (*configNode, error) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.SequenceNode:
+		list := &configNode{kind: listNode, line: node.Line}
+		for _, item := range node.Content {
+			value, err := yamlNode(item)
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, value)
+		}
+		return list, nil
+
+	case yaml.MappingNode:
+		table := newTable(node.Line)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, configLineError(key.Line, errors.New("keys must be plain values"))
+			}
+			value, err := yamlNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			//A VALUE IS REPORTED ON THE LINE OF ITS KEY
+			value.line = key.Line
+			if err := table.set(key.Value, value); err != nil {
+				return nil, err
+			}
+		}
+		return table, nil
+	}
+
+	if node.Tag == "!!null" {
+		return &configNode{line: node.Line}, nil
+	}
+	return &configNode{line: node.Line, value: node.Value}, nil
+}
+
+// yamlConfigError turns the "yaml: line N: ..." of a syntax error into the
+// line of a configError.
+func yamlConfigError(err error) error {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	if rest, ok := strings.CutPrefix(message, "line "); ok {
+		number, message, ok := strings.Cut(rest, ": ")
+		if line, convErr := strconv.Atoi(number); ok && convErr == nil {
+			return configLineError(line, errors.New(message))
+		}
+	}
+	return errors.New(message)
+}
+
+// errTOMLPosition is returned by tomlPosition to have the decoder report the
+// line of the key it is decoding.
+var errTOMLPosition = errors.New("position")
+
+// tomlPosition decodes any value into the error that carries its line.
+type tomlPosition struct{}
+
+func (*tomlPosition) UnmarshalTOML(any) error {
+	return errTOMLPosition
+}
+
+// tomlDecoder turns decoded TOML into configNodes, with the line of every key.
+type tomlDecoder struct {
+	meta  toml.MetaData
+	order map[string]int
+	count map[string]int
+}
+
+func parseTOMLConfig(data []byte) (*configNode, error) {
+	var values map[string]toml.Primitive
+	meta, err := toml.Decode(string(data), &values)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, configLineError(parseErr.Position.Line, errors.New(parseErr.Message))
+		}
+		return nil, err
+	}
+
+	decoder := &tomlDecoder{meta: meta, order: make(map[string]int), count: make(map[string]int)}
+	for i, key := range meta.Keys() {
+		if _, ok := decoder.order[key.String()]; !ok {
+			decoder.order[key.String()] = i
+		}
+		decoder.count[key.String()]++
+	}
+	return decoder.table(values, nil, 1)
+}
+
+// line returns the line of the key at path. The keys of the tables of a
+// [[list]] share their path, so when the path repeats it returns 0, no line.
+func (d *tomlDecoder) line(value toml.Primitive, path toml.Key) int {
+	if d.count[path.String()] != 1 {
+		return 0
+	}
+	var parseErr toml.ParseError
+	if err := d.meta.PrimitiveDecode(value, &tomlPosition{}); errors.As(err, &parseErr) {
+		return parseErr.Position.Line
+	}
+	return 0
+}
+
+func (d *tomlDecoder) table(values map[string]toml.Primitive, path toml.Key, line int) (*configNode, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return d.order[append(slices.Clip(path), a).String()] - d.order[append(slices.Clip(path), b).String()]
+	})
+
+	node := newTable(line)
+	for _, key := range keys {
+		keyPath := append(slices.Clip(path), key)
+		value, err := d.value(values[key], keyPath, d.line(values[key], keyPath))
+		if err != nil {
+			return nil, err
+		}
+		if err := node.set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (d *tomlDecoder) value(value toml.Primitive, path toml.Key, line int) (*configNode, error) {
+	switch d.meta.Type(path...) {
+	case "Hash":
+		var values map[string]toml.Primitive
+		if err := d.meta.PrimitiveDecode(value, &values); err != nil {
+			return nil, configLineError(line, err)
+		}
+		return d.table(values, path, line)
+
+	case "Array", "ArrayHash":
+		var items []toml.Primitive
+		if err := d.meta.PrimitiveDecode(value, &items); err != nil {
+			return nil, configLineError(line, err)
+		}
+		node := &configNode{kind: listNode, line: line}
+		for _, item := range items {
+			var decoded any
+			if err := d.meta.PrimitiveDecode(item, &decoded); err != nil {
+				return nil, configLineError(line, err)
+			}
+
+			//[[name]] TABLES AND INLINE TABLES ARE ITEMS OF THE SAME LIST
+			var child *configNode
+			var err error
+			if _, ok := decoded.(map[string]any); ok {
+				var values map[string]toml.Primitive
+				if err := d.meta.PrimitiveDecode(item, &values); err != nil {
+					return nil, configLineError(line, err)
+				}
+				child, err = d.table(values, path, line)
+			} else {
+				child, err = tomlScalar(decoded, line)
+			}
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, child)
+		}
+		return node, nil
+	}
+
+	var scalar any
+	if err := d.meta.PrimitiveDecode(value, &scalar); err != nil {
+		return nil, configLineError(line, err)
+	}
+	return tomlScalar(scalar, line)
+}
+
+// tomlScalar turns a decoded TOML value into the text its flag parses.
+func tomlScalar(value any, line int) (*configNode, error) {
+	switch value := value.(type) {
+	case string:
+		return &configNode{line: line, value: value}, nil
+	case int64:
+		return &configNode{line: line, value: strconv.FormatInt(value, 10)}, nil
+	case float64:
+		return &configNode{line: line, value: strconv.FormatFloat(value, 'g', -1, 64)}, nil
+	case bool:
+		return &configNode{line: line, value: strconv.FormatBool(value)}, nil
+	case map[string]any, []any, []map[string]any:
+		return nil, configLineError(line, errors.New("lists may only hold values"))
+	}
+	return nil, configLineError(line, fmt.Errorf("%v is not a setting value", value))
+}
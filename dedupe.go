@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// runDedupe implements "dedupe [flags] RESULTS" and returns the exit code.
+// Nothing is changed without -apply.
+func runDedupe(args []string) int {
+	flags := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	actionFlag := flags.String("action", scanner.DedupeHardlink, "Replace duplicates with a hardlink, symlink or reflink, or delete them")
+	keepFlag := flags.String("keep", scanner.KeepShortest, "Copy to keep: oldest, shortest (path) or priority")
+	applyFlag := flags.Bool("apply", false, "Make the changes instead of only reporting them")
+	var priorityFlags stringListFlag
+	flags.Var(&priorityFlags, "prefer", "Directory whose copies are kept first with -keep=priority, repeatable in order of preference")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s dedupe [flags] RESULTS\n\nReplace the duplicates of a saved scan with links, or delete them.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	switch *actionFlag {
+	case scanner.DedupeHardlink, scanner.DedupeSymlink, scanner.DedupeReflink, scanner.DedupeDelete:
+	default:
+		fmt.Printf("Error: -action must be hardlink, symlink, reflink or delete\n")
+		return exitUsage
+	}
+	switch *keepFlag {
+	case scanner.KeepOldest, scanner.KeepShortest:
+	case scanner.KeepPriority:
+		if len(priorityFlags) == 0 {
+			fmt.Printf("Error: -keep=priority needs at least one -prefer directory\n")
+			return exitUsage
+		}
+	default:
+		fmt.Printf("Error: -keep must be oldest, shortest or priority\n")
+		return exitUsage
+	}
+
+	scan, err := scanner.LoadScanResults(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	options := scanner.DedupeOptions{Action: *actionFlag, Keep: *keepFlag, Priority: priorityFlags}
+	groups := scanner.PlanDedupe(scan.Duplicates, options)
+
+	verb := "would " + *actionFlag
+	if *applyFlag {
+		verb = *actionFlag
+	}
+
+	var planned, reclaimed int64
+	files, failures := 0, 0
+	for _, group := range groups {
+		fmt.Printf("%s\n", group.Hash)
+		if group.Keep != "" {
+			fmt.Printf("  keep    %s\n", group.Keep)
+		}
+
+		failed := map[string]error{}
+		if *applyFlag && len(group.Replace) > 0 {
+			var groupReclaimed int64
+			groupReclaimed, failed = scanner.ApplyDedupe(group, *actionFlag)
+			reclaimed += groupReclaimed
+		}
+		for _, path := range group.Replace {
+			if err, ok := failed[path]; ok {
+				fmt.Printf("  FAILED  %s: %v\n", path, err)
+				failures++
+				continue
+			}
+			fmt.Printf("  %s %s\n", verb, path)
+			files++
+			planned += group.Size
+		}
+		for _, path := range slices.Sorted(maps.Keys(group.Skipped)) {
+			fmt.Printf("  skip    %s: %s\n", path, group.Skipped[path])
+		}
+	}
+
+	if *applyFlag {
+		fmt.Printf("Replaced %d files, reclaimed %d bytes, %d failed\n", files, reclaimed, failures)
+	} else {
+		fmt.Printf("Dry run: %d files in %d groups could be replaced, reclaiming %d bytes. Rerun with -apply to make the changes.\n", files, len(groups), planned)
+	}
+
+	if failures > 0 {
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// runDiff implements "diff [-json] old.json new.json" and returns the exit code.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	jsonFlag := flags.Bool("json", false, "Print the diff as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [-json] OLD_RESULTS NEW_RESULTS\n\nCompare two saved scans or inventories.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	oldScan, err := scanner.LoadScanResults(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+	newScan, err := scanner.LoadScanResults(flags.Arg(1))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	diff := scanner.DiffScans(oldScan, newScan)
+	diff.Old, diff.New = flags.Arg(0), flags.Arg(1)
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", " ")
+		if err := encoder.Encode(diff); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+	diff.WriteText(os.Stdout)
+	return exitOK
+}
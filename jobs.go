@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// States of a scan job.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+	JobFailed    = "failed"
+)
+
+// maxQueuedJobs is how many submitted jobs may wait for a runner.
+const maxQueuedJobs = 1000
+
+// errQueueFull is returned by Submit when maxQueuedJobs jobs are waiting.
+var errQueueFull = errors.New("too many scans queued")
+
+// JobRequest is the body of POST /scans. Fields left empty keep the settings
+// the service was started with. Workers, MaxSize and ArchiveDepth may only
+// lower them.
+type JobRequest struct {
+	Roots         []string `json:"roots"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	Workers       int      `json:"workers"`
+	MaxSize       int64    `json:"max_size"`
+	ArchiveDepth  int      `json:"archive_depth"`
+	Hash          []string `json:"hash"`
+	TypeBy        string   `json:"type_by"`
+	Prefilter     *bool    `json:"prefilter"`
+	Verify        bool     `json:"verify"`
+	DirDuplicates bool     `json:"dir_duplicates"`
+	Similarity    float64  `json:"similarity"`
+}
+
+// JobStatus is what /scans and /scans/{id}/status report about a job.
+type JobStatus struct {
+	ID           string     `json:"id"`
+	State        string     `json:"state"`
+	Roots        []string   `json:"roots"`
+	Submitted    time.Time  `json:"submitted"`
+	Started      *time.Time `json:"started,omitempty"`
+	Finished     *time.Time `json:"finished,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	FilesScanned int        `json:"files_scanned"`
+	FilesPending int        `json:"files_pending"`
+	TotalBytes   int64      `json:"total_bytes"`
+	ErrorsCount  int        `json:"errors_count"`
+}
+
+// Job is one submitted scan. Once it finishes only its saved results are
+// kept, the Scanner is let go.
+type Job struct {
+	id    string
+	roots []string
+
+	state     string
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+	err       string
+
+	scan   *scanner.Scanner
+	cancel context.CancelFunc
+	result *scanner.ScanMetrics
+}
+
+// JobQueue runs submitted scans in order, a few at a time, and keeps the
+// finished ones around as history.
+type JobQueue struct {
+	config  scanner.ScanConfig
+	options []scanner.Option
+	allowed []string
+	history int
+
+	queue chan *Job
+
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	order  []string
+	nextID int
+}
+
+// NewJobQueue makes a queue whose jobs start from config and options, may only
+// scan the allowed directories and what is below them, and keeps the last
+// history finished jobs.
+func NewJobQueue(config scanner.ScanConfig, options []scanner.Option, allowed []string, history int) *JobQueue {
+	resolved := make([]string, 0, len(allowed))
+	for _, root := range allowed {
+		resolved = append(resolved, resolvePath(root))
+	}
+	return &JobQueue{
+		config:  config,
+		options: options,
+		allowed: resolved,
+		history: history,
+		queue:   make(chan *Job, maxQueuedJobs),
+		jobs:    make(map[string]*Job),
+	}
+}
+
+// Submit checks request and queues a scan of it.
+func (q *JobQueue) Submit(request JobRequest) (JobStatus, error) {
+	s, roots, err := q.newScanner(request)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	job := &Job{
+		id:        strconv.Itoa(q.nextID),
+		roots:     roots,
+		state:     JobQueued,
+		submitted: time.Now(),
+		scan:      s,
+	}
+
+	select {
+	case q.queue <- job:
+	default:
+		q.nextID--
+		return JobStatus{}, errQueueFull
+	}
+	q.jobs[job.id] = job
+	q.order = append(q.order, job.id)
+	return q.status(job), nil
+}
+
+// jobConfig applies request to the settings of the queue.
+func (q *JobQueue) jobConfig(request JobRequest) (scanner.ScanConfig, error) {
+	config := q.config
+	if len(request.Roots) > 0 {
+		config.Directories = request.Roots
+	}
+	if len(config.Directories) == 0 {
+		return config, errors.New("roots are required")
+	}
+	for _, root := range config.Directories {
+		if !q.allows(root) {
+			return config, fmt.Errorf("%s is not under the allowed roots", root)
+		}
+	}
+	if request.Include != nil {
+		config.Include = request.Include
+	}
+	if request.Exclude != nil {
+		config.Exclude = request.Exclude
+	}
+
+	//A JOB MAY ASK FOR LESS THAN THE SERVICE'S LIMITS, NEVER FOR MORE
+	if request.Workers < 0 || request.MaxSize < 0 || request.ArchiveDepth < 0 {
+		return config, errors.New("workers, max_size and archive_depth must not be negative")
+	}
+	if request.Workers > 0 {
+		maxWorkers := config.WorkerCount
+		if maxWorkers <= 0 {
+			maxWorkers = runtime.NumCPU()
+		}
+		config.WorkerCount = min(request.Workers, maxWorkers)
+	}
+	if request.MaxSize > 0 && (config.MaxFileSize <= 0 || request.MaxSize < config.MaxFileSize) {
+		config.MaxFileSize = request.MaxSize
+	}
+	if request.ArchiveDepth > 0 {
+		config.ArchiveDepth = min(request.ArchiveDepth, config.ArchiveDepth)
+	}
+	if request.TypeBy != "" {
+		config.TypeCountBy = request.TypeBy
+	}
+	if request.Prefilter != nil {
+		config.Prefilter = *request.Prefilter
+	}
+
+	//THE DENYLIST STILL NEEDS ITS ALGORITHMS WHEN A JOB PICKS ITS OWN
+	if len(request.Hash) > 0 {
+		algorithms, err := scanner.ParseHashAlgorithms(strings.Join(request.Hash, ","))
+		if err != nil {
+			return config, err
+		}
+		if config.Denylist != nil {
+			for _, algorithm := range config.Denylist.Algorithms() {
+				if !containsString(algorithms, algorithm) {
+					algorithms = append(algorithms, algorithm)
+				}
+			}
+		}
+		config.HashAlgorithms = algorithms
+	}
+
+	//THE CACHE ONLY HOLDS THE SERVICE'S ALGORITHMS, OTHERS WOULD EVICT THEM
+	if !slices.Equal(config.HashAlgorithms, q.config.HashAlgorithms) {
+		config.HashCache = nil
+	}
+	return config, nil
+}
+
+// newScanner builds the Scanner of a job and returns it with its roots.
+func (q *JobQueue) newScanner(request JobRequest) (*scanner.Scanner, []string, error) {
+	config, err := q.jobConfig(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := slices.Clone(q.options)
+	if request.Verify {
+		options = append(options, scanner.WithVerify())
+	}
+	if request.DirDuplicates {
+		options = append(options, scanner.WithDirectoryDuplicates())
+	}
+	if request.Similarity != 0 {
+		options = append(options, scanner.WithSimilarity(request.Similarity))
+	}
+
+	s, err := scanner.New(config, options...)
+	return s, config.Directories, err
+}
+
+// allows reports whether root is one of the allowed roots or below one.
+func (q *JobQueue) allows(root string) bool {
+	root = resolvePath(root)
+	for _, allowed := range q.allowed {
+		rel, err := filepath.Rel(allowed, root)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath makes path absolute and follows its symlinks, so a link cannot
+// lead a job out of the allowed roots. A path that does not exist is only
+// made absolute; scanning it fails anyway.
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// Run runs up to workers jobs at a time until ctx is cancelled, which also
+// cancels the running jobs.
+func (q *JobQueue) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case job := <-q.queue:
+					q.run(ctx, job)
+
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *JobQueue) run(ctx context.Context, job *Job) {
+	q.mu.Lock()
+	//CANCELLED WHILE IT WAS WAITING
+	if job.state != JobQueued {
+		q.mu.Unlock()
+		return
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	job.state = JobRunning
+	job.started = time.Now()
+	job.cancel = cancel
+	q.mu.Unlock()
+
+	fmt.Printf("Scan %s started: %v\n", job.id, job.roots)
+	metrics, err := job.scan.Run(jobCtx)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job.finished = time.Now()
+	switch {
+	case err == nil:
+		job.state = JobCompleted
+	case errors.Is(err, context.Canceled):
+		job.state = JobCancelled
+	default:
+		job.state = JobFailed
+		job.err = err.Error()
+	}
+	if metrics != nil {
+		result := scanner.CollectRealMetrics(metrics)
+		job.result = &result
+	}
+	job.scan = nil
+	job.cancel = nil
+	fmt.Printf("Scan %s %s\n", job.id, job.state)
+
+	q.trimHistory()
+}
+
+// trimHistory forgets the oldest finished jobs beyond the history limit.
+func (q *JobQueue) trimHistory() {
+	finished := 0
+	for i := len(q.order) - 1; i >= 0; i-- {
+		job := q.jobs[q.order[i]]
+		if job.state == JobQueued || job.state == JobRunning {
+			continue
+		}
+		finished++
+		if finished > q.history {
+			delete(q.jobs, job.id)
+			q.order = slices.Delete(q.order, i, i+1)
+		}
+	}
+}
+
+// Status reports the job with id.
+func (q *JobQueue) Status(id string) (JobStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return q.status(job), true
+}
+
+// List reports every job, newest first.
+func (q *JobQueue) List() []JobStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(q.order))
+	for i := len(q.order) - 1; i >= 0; i-- {
+		statuses = append(statuses, q.status(q.jobs[q.order[i]]))
+	}
+	return statuses
+}
+
+func (q *JobQueue) status(job *Job) JobStatus {
+	status := JobStatus{
+		ID:        job.id,
+		State:     job.state,
+		Roots:     job.roots,
+		Submitted: job.submitted,
+		Error:     job.err,
+	}
+	if started := job.started; !started.IsZero() {
+		status.Started = &started
+	}
+	if finished := job.finished; !finished.IsZero() {
+		status.Finished = &finished
+	}
+
+	switch {
+	case job.result != nil:
+		status.FilesScanned = job.result.FilesScanned
+		status.FilesPending = job.result.FilesPending
+		status.TotalBytes = job.result.TotalBytes
+		status.ErrorsCount = len(job.result.Errors)
+	case job.scan != nil:
+		progress := job.scan.Progress()
+		status.FilesScanned = progress.FilesScanned
+		status.FilesPending = progress.FilesPending
+		status.TotalBytes = progress.TotalBytes
+		status.ErrorsCount = progress.Errors
+	}
+	return status
+}
+
+// Metrics returns the metrics of the job with id, as /metrics reports them,
+// whether it is still running or finished.
+func (q *JobQueue) Metrics(id string) (scanner.ScanMetrics, bool) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return scanner.ScanMetrics{}, false
+	}
+	result, scan := job.result, job.scan
+	q.mu.Unlock()
+
+	if result != nil {
+		return *result, true
+	}
+	var metrics scanner.ScanMetrics
+	if scan != nil {
+		scan.View(func(m *scanner.ScanMetrics) {
+			metrics = scanner.CollectRealMetrics(m)
+		})
+	}
+	return metrics, true
+}
+
+// savedMetrics serves the saved metrics of a finished job like a running scan.
+type savedMetrics struct {
+	metrics *scanner.ScanMetrics
+}
+
+func (m savedMetrics) View(fn func(metrics *scanner.ScanMetrics)) {
+	fn(m.metrics)
+}
+
+// View returns the metrics of the job with id for the endpoints shared with
+// scan: the live ones while it is queued or running, the saved ones after.
+func (q *JobQueue) View(id string) (metricsView, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	switch {
+	case !ok:
+		return nil, false
+	case job.result != nil:
+		return savedMetrics{job.result}, true
+	case job.scan != nil:
+		return job.scan, true
+	}
+	return savedMetrics{&scanner.ScanMetrics{}}, true
+}
+
+// Cancel stops the job with id, or takes it off the queue if it has not
+// started. It reports whether the job exists and whether it was still queued
+// or running.
+func (q *JobQueue) Cancel(id string) (found, stopped bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return false, false
+	}
+	switch job.state {
+	case JobQueued:
+		job.state = JobCancelled
+		job.finished = time.Now()
+		job.scan = nil
+		q.trimHistory()
+		return true, true
+	case JobRunning:
+		job.cancel()
+		return true, true
+	}
+	return true, false
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// TestJobQueue tests that jobs run in order, can be cancelled while queued
+// and that only the newest finished jobs are kept
+func TestJobQueue(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue := NewJobQueue(scanner.ScanConfig{}, nil, []string{dir}, 1)
+	if _, err := queue.Submit(JobRequest{}); err == nil {
+		t.Errorf("Expected an error for a job without roots")
+	}
+
+	var ids []string
+	for range 3 {
+		status, err := queue.Submit(JobRequest{Roots: []string{dir}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if status.State != JobQueued {
+			t.Errorf("Expected a new job to be queued, got %s", status.State)
+		}
+		ids = append(ids, status.ID)
+	}
+	if found, stopped := queue.Cancel(ids[1]); !found || !stopped {
+		t.Errorf("Expected the queued job to be cancelled, got %v and %v", found, stopped)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx, 1)
+		close(done)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, ok := queue.Status(ids[2])
+		if ok && status.State == JobCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the last job to complete, got %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	jobs := queue.List()
+	if len(jobs) != 1 || jobs[0].ID != ids[2] {
+		t.Fatalf("Expected only the newest finished job in the history, got %+v", jobs)
+	}
+	if jobs[0].FilesScanned != 2 {
+		t.Errorf("Expected 2 files scanned, got %d", jobs[0].FilesScanned)
+	}
+	metrics, ok := queue.Metrics(ids[2])
+	if !ok || len(metrics.Duplicates) != 1 {
+		t.Errorf("Expected one duplicate group in the saved metrics, got %v", metrics.Duplicates)
+	}
+	if found, stopped := queue.Cancel(ids[2]); !found || stopped {
+		t.Errorf("Expected a finished job not to be stopped again, got %v and %v", found, stopped)
+	}
+}
+
+// TestJobQueue_Config tests that a job stays inside the allowed roots and
+// below the service's limits
+func TestJobQueue_Config(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(allowed, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	service := scanner.ScanConfig{
+		WorkerCount:    4,
+		MaxFileSize:    1000,
+		ArchiveDepth:   2,
+		Prefilter:      true,
+		HashAlgorithms: []string{"sha256"},
+		HashCache:      &scanner.HashCache{},
+	}
+	queue := NewJobQueue(service, nil, []string{allowed}, 1)
+
+	for _, roots := range [][]string{{outside}, {filepath.Join(allowed, "..")}, {filepath.Join(allowed, "link")}} {
+		if _, err := queue.jobConfig(JobRequest{Roots: roots}); err == nil {
+			t.Errorf("Expected an error for roots %v", roots)
+		}
+	}
+	if _, err := queue.jobConfig(JobRequest{Roots: []string{allowed}, Workers: -1}); err == nil {
+		t.Error("Expected an error for negative workers")
+	}
+
+	off := false
+	config, err := queue.jobConfig(JobRequest{
+		Roots:        []string{filepath.Join(allowed, "sub")},
+		Workers:      1000000000,
+		MaxSize:      1 << 40,
+		ArchiveDepth: 100,
+		Prefilter:    &off,
+		Hash:         []string{"SHA256", " md5"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.WorkerCount != 4 || config.MaxFileSize != 1000 || config.ArchiveDepth != 2 {
+		t.Errorf("Expected the service's limits, got %d workers, %d bytes and depth %d", config.WorkerCount, config.MaxFileSize, config.ArchiveDepth)
+	}
+	if config.Prefilter {
+		t.Error("Expected the job to turn the prefilter off")
+	}
+	if strings.Join(config.HashAlgorithms, ",") != "sha256,md5" || config.HashCache != nil {
+		t.Errorf("Expected normalized algorithms without the cache, got %v and %v", config.HashAlgorithms, config.HashCache)
+	}
+
+	config, err = queue.jobConfig(JobRequest{Workers: 2, MaxSize: 10, Hash: []string{"sha256"}, Roots: []string{allowed}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.WorkerCount != 2 || config.MaxFileSize != 10 || !config.Prefilter || config.HashCache == nil {
+		t.Errorf("Expected lower limits, the prefilter and the cache, got %+v", config)
+	}
+}
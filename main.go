@@ -1,104 +1,269 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
-	"sync"
-	"time"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"Distributed_Artifact_Scanner/scanner"
 )
 
+// Exit codes shared by every command.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+
+	// exitDenylistMatch is the exit code when a scanned file is on the denylist.
+	exitDenylistMatch = 3
+
+	// exitBaselineMismatch is the exit code when a scan differs from its manifest.
+	exitBaselineMismatch = 4
+
+	// exitIncomplete is the exit code when a scan was interrupted or cancelled
+	// before every file was collected.
+	exitIncomplete = 5
+)
+
+// stdoutLogger prints the messages of the scanner library alongside the
+// output of the commands.
+var stdoutLogger = log.New(os.Stdout, "", 0)
+
+// command is a subcommand of the scanner binary.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"scan", "Scan directories once, print a summary and save the results (default)", runScan},
+	{"serve", "Run scan jobs submitted to POST /scans until stopped", runServe},
+	{"verify", "Check directories against a manifest", runVerify},
+	{"diff", "Compare two saved scans", runDiff},
+	{"dedupe", "Replace the duplicates of a saved scan with links, or delete them", runDedupe},
+	{"report", "Print the summary of a saved scan", runReport},
+	{"config", "Check a config file: config validate FILE", runConfig},
+}
+
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	//GET CONFIG FROM CMD ARGUMENTS
-	var (
-		dirFlag     = flag.String("dir", ".", "Directory containing files")
-		workersFlag = flag.Int("workers", 4, "Number of concurrent workers")
-		maxSizeFlag = flag.Int64("max-size", 100*1024*1024, "Maximum amount of files to scan")
-	)
+// run dispatches args to their command and returns the exit code. Without a
+// command, or when args start with a flag, they are scan flags.
+func run(args []string) int {
+	if len(args) == 0 {
+		return runScan(args)
+	}
 
-	flag.Parse()
+	name := args[0]
+	switch {
+	case name == "help" || name == "-h" || name == "-help" || name == "--help":
+		if len(args) == 1 {
+			usage()
+			return exitOK
+		}
+		name, args = args[1], []string{args[1], "-h"}
+	case strings.HasPrefix(name, "-"):
+		return runScan(args)
+	}
 
-	config := ScanConfig{
-		Directories: []string{*dirFlag},
-		WorkerCount: *workersFlag,
-		MaxFileSize: *maxSizeFlag,
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", name)
+		usage()
+		return exitUsage
 	}
+	return cmd.run(args[1:])
+}
 
-	//INITIALIZE CHANNELS
-	tasksChannel := make(chan FileTask, 100)
-	resultsChannel := make(chan ScanResult, 100)
-	doneChannel := make(chan struct{})
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
 
-	metrics := &ScanMetrics{
-		StartTime:  time.Now(),
-		Duplicates: make(map[string][]string),
-		TypeCount:  make(map[string]int),
-		Errors:     make([]FileError, 0),
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun \"%s help <command>\" for the flags of a command.\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nExit codes: %d success, %d error, %d bad usage, %d denylisted file, %d manifest mismatch, %d scan incomplete\n",
+		exitOK, exitError, exitUsage, exitDenylistMatch, exitBaselineMismatch, exitIncomplete)
+}
 
-	metricsMutex := &sync.RWMutex{}
+// parseFlags parses args and reports whether the command should go on. When
+// it should not, the returned exit code is the one to stop with: success after
+// -h, bad usage otherwise.
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	switch {
+	case err == nil:
+		return exitOK, true
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK, false
+	default:
+		return exitUsage, false
+	}
+}
 
-	//	CREATE NEW SERVER
-	server := NewServer(metrics, doneChannel, metricsMutex)
-	server.Start()
+// signalContext is cancelled by Ctrl-C or SIGTERM so a scan stops gracefully.
+// A second signal kills the process.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
 
-	//GOROUTINE FOR DISCOVERING FILES
-	go DiscoverFiles(config, tasksChannel, doneChannel, metrics, metricsMutex)
+// summarySections picks what printSummary lists beyond the totals and waste.
+type summarySections struct {
+	top            int
+	directories    bool
+	nearDuplicates bool
+	secrets        bool
+}
 
-	var workerWaitGroup sync.WaitGroup
-	workerWaitGroup.Add(config.WorkerCount)
+// printSummary prints the totals of a scan, its wasted space and the reports
+// picked by sections, then any denylisted file and the baseline comparison.
+func printSummary(metrics *scanner.ScanMetrics, sections summarySections) {
+	fmt.Printf("Files scanned: %d \n", metrics.FilesScanned)
+	fmt.Printf("Duplicates: %d \n", countDuplicates(metrics))
+	fmt.Printf("Total bytes: %d\n", metrics.TotalBytes)
+	printWaste(metrics, sections.top)
+	if sections.directories {
+		printDuplicateDirectories(metrics, sections.top)
+	}
+	if sections.nearDuplicates {
+		printNearDuplicates(metrics, sections.top)
+	}
+	fmt.Printf("Errors: %d \n", len(metrics.Errors))
+	if sections.secrets {
+		fmt.Printf("Secret findings: %d \n", len(metrics.Findings))
+	}
 
-	for i := 0; i < config.WorkerCount; i++ {
-		go func(id int) {
-			WorkerProcessFiles(id, tasksChannel, resultsChannel, doneChannel)
-			workerWaitGroup.Done()
+	for _, match := range metrics.DenylistMatches {
+		fmt.Printf("DENYLISTED: %s (%s %s) %s\n", match.Path, match.Algorithm, match.Hash, match.Label)
+	}
+	if baseline := metrics.Baseline; baseline != nil {
+		fmt.Printf("Baseline: %d of %d matched, %d new, %d missing, %d changed\n",
+			baseline.Matched, baseline.Expected, len(baseline.New), len(baseline.Missing), len(baseline.Changed))
+		printPaths("NEW", baseline.New)
+		printPaths("MISSING", baseline.Missing)
+		printPaths("CHANGED", baseline.Changed)
+		printPaths("UNREADABLE", baseline.Unreadable)
+	}
+}
 
-		}(i)
+// scanExitCode is the exit code of a scan: denylisted content fails it first
+// so CI can gate on it, then an unfinished scan, then drift from its baseline.
+func scanExitCode(metrics *scanner.ScanMetrics, completed bool) int {
+	switch {
+	case len(metrics.DenylistMatches) > 0:
+		return exitDenylistMatch
+	case !completed:
+		return exitIncomplete
+	case metrics.Baseline != nil && !metrics.Baseline.Clean():
+		return exitBaselineMismatch
+	default:
+		return exitOK
 	}
+}
 
-	collectorDone := make(chan struct{})
-	go func() {
-		CollectResults(resultsChannel, doneChannel, metrics, metricsMutex)
-		metrics.EndTime = time.Now()
-		close(collectorDone)
-	}()
+// printWaste lists the duplicate groups and directories wasting the most space.
+func printWaste(metrics *scanner.ScanMetrics, limit int) {
+	groups := scanner.DuplicateGroupsByWaste(metrics)
+	fmt.Printf("Reclaimable bytes: %d\n", scanner.ReclaimableBytes(groups))
+	if limit <= 0 || len(groups) == 0 {
+		return
+	}
 
-	go func() {
-		workerWaitGroup.Wait()
-		close(resultsChannel)
-	}()
+	fmt.Printf("Top duplicate groups by wasted space:\n")
+	for _, group := range scanner.TopN(groups, limit) {
+		fmt.Printf("  %12d bytes  %d x %d bytes  %s\n", group.Reclaimable, group.Copies, group.Size, group.Paths[0])
+	}
 
-	select {
-	case <-collectorDone:
-		fmt.Println("Scan Completed Successfully")
+	fmt.Printf("Top directories by wasted space:\n")
+	for _, directory := range scanner.TopN(scanner.DirectoriesByWaste(groups), limit) {
+		fmt.Printf("  %12d bytes  %d files  %s\n", directory.Reclaimable, directory.Files, directory.Directory)
+	}
+}
 
-	case <-doneChannel:
-		fmt.Println("Scan cancelled by user")
+// printDuplicateDirectories lists the largest identical directories and
+// directories contained in others.
+func printDuplicateDirectories(metrics *scanner.ScanMetrics, limit int) {
+	fmt.Printf("Identical directory groups: %d\n", len(metrics.DuplicateDirectories))
+	for _, group := range scanner.TopN(metrics.DuplicateDirectories, limit) {
+		fmt.Printf("  %d files, %d bytes: %s\n", group.Files, group.Bytes, strings.Join(group.Directories, ", "))
 	}
+	fmt.Printf("Directories contained in others: %d\n", len(metrics.SubsetDirectories))
+	for _, subset := range scanner.TopN(metrics.SubsetDirectories, limit) {
+		fmt.Printf("  %s is a subset of %s (%d files)\n", subset.Subset, subset.Superset, subset.Files)
+	}
+}
 
-	metricsMutex.Lock()
-	metrics.EndTime = time.Now()
-	metricsMutex.Unlock()
+// printNearDuplicates lists the largest groups of similar files with the
+// similarity of each file to the first one.
+func printNearDuplicates(metrics *scanner.ScanMetrics, limit int) {
+	fmt.Printf("Near-duplicate groups: %d\n", len(metrics.NearDuplicates))
+	for _, group := range scanner.TopN(metrics.NearDuplicates, limit) {
+		fmt.Printf("  %d files, similarity %.2f or more:\n", len(group.Files), group.MinSimilarity)
+		for _, file := range group.Files {
+			fmt.Printf("    %.2f  %s\n", file.Similarity, file.Path)
+		}
+	}
+}
 
-	err := saveResults(metrics, "Scan_Results.json")
-	if err != nil {
-		fmt.Printf("Error saving results: %v\n", err)
-	} else {
-		fmt.Println("Results saved to scan-results.json")
+func printPaths(label string, paths []string) {
+	for _, path := range paths {
+		fmt.Printf("%s: %s\n", label, path)
 	}
+}
 
-	server.Stop()
-	metricsMutex.RLock()
-	fmt.Printf("Files scanned: %d \n", metrics.FilesScanned)
-	fmt.Printf("Duplicates: %d \n", countDuplicates(metrics))
-	fmt.Printf("Total bytes: %d\n", metrics.TotalBytes)
-	fmt.Printf("Errors: %d \n", len(metrics.Errors))
-	metricsMutex.RUnlock()
+// stringListFlag collects every value of a repeatable flag.
+type stringListFlag []string
+
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
 }
 
-func countDuplicates(metrics *ScanMetrics) int {
+func (s *stringListFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func closeHashCache(cache *scanner.HashCache) {
+	if cache == nil {
+		return
+	}
+	if err := cache.Close(); err != nil {
+		fmt.Printf("Error saving hash cache: %v\n", err)
+	}
+}
+
+func countDuplicates(metrics *scanner.ScanMetrics) int {
 	count := 0
 	for _, paths := range metrics.Duplicates {
 		if len(paths) > 1 { // Only count actual duplicates
@@ -107,17 +272,3 @@ func countDuplicates(metrics *ScanMetrics) int {
 	}
 	return count
 }
-
-func saveResults(metrics *ScanMetrics, fileName string) error {
-	file, err := os.Create(fileName)
-	if err != nil {
-		return err
-	}
-
-	metricsResult := CollectRealMetrics(metrics)
-
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ")
-	return encoder.Encode(metricsResult)
-}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// TestRun_ExitCodes tests the exit codes of commands that stop before doing any work
+func TestRun_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"help", []string{"help"}, exitOK},
+		{"command help", []string{"help", "diff"}, exitOK},
+		{"help flag", []string{"report", "-h"}, exitOK},
+		{"unknown command", []string{"rescan"}, exitUsage},
+		{"unknown help", []string{"help", "rescan"}, exitUsage},
+		{"unknown flag", []string{"scan", "-nope"}, exitUsage},
+		{"missing argument", []string{"verify"}, exitUsage},
+		{"bad mode", []string{"-mode=peer"}, exitUsage},
+		{"missing results", []string{"report", filepath.Join(t.TempDir(), "missing.json")}, exitError},
+	}
+
+	for _, tc := range tests {
+		if code := run(tc.args); code != tc.expected {
+			t.Errorf("Expected %s to exit with %d, got %d", tc.name, tc.expected, code)
+		}
+	}
+}
+
+// TestScanExitCode tests that denylisted files win over an unfinished scan and
+// an unfinished scan over drift from the baseline
+func TestScanExitCode(t *testing.T) {
+	drifted := &scanner.BaselineReport{Missing: []string{"/srv/a.bin"}}
+	denylisted := []scanner.DenylistMatch{{Path: "/srv/evil.bin"}}
+
+	tests := []struct {
+		name      string
+		metrics   scanner.ScanMetrics
+		completed bool
+		expected  int
+	}{
+		{"clean", scanner.ScanMetrics{Baseline: &scanner.BaselineReport{}}, true, exitOK},
+		{"drifted", scanner.ScanMetrics{Baseline: drifted}, true, exitBaselineMismatch},
+		{"incomplete", scanner.ScanMetrics{Baseline: drifted}, false, exitIncomplete},
+		{"denylisted", scanner.ScanMetrics{Baseline: drifted, DenylistMatches: denylisted}, false, exitDenylistMatch},
+	}
+
+	for _, tc := range tests {
+		if code := scanExitCode(&tc.metrics, tc.completed); code != tc.expected {
+			t.Errorf("Expected %s scan to exit with %d, got %d", tc.name, tc.expected, code)
+		}
+	}
+}
+
+// TestRunVerify tests verifying a tree against the manifest of an earlier scan
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("release"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(t.TempDir(), "release.sha256")
+	if code := run([]string{"scan", "-dir", dir, "-http=", "-results=", "-write-manifest", manifest}); code != exitOK {
+		t.Fatalf("Expected scan to succeed, got %d", code)
+	}
+
+	if code := run([]string{"verify", "-dir", dir, manifest}); code != exitOK {
+		t.Errorf("Expected unchanged tree to verify, got %d", code)
+	}
+
+	if err := os.WriteFile(file, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := run([]string{"verify", "-dir", dir, manifest}); code != exitBaselineMismatch {
+		t.Errorf("Expected changed file to exit with %d, got %d", exitBaselineMismatch, code)
+	}
+}
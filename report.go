@@ -0,0 +1,72 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// runReport implements "report [flags] RESULTS" and returns the exit code. It
+// prints the summary a scan printed, and its file types and findings, from
+// the saved results or an inventory.
+func runReport(args []string) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	topFlag := flags.Int("top", scanner.DefaultTopN, "Number of most wasteful duplicate groups, directories and file types listed")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s report [flags] RESULTS\n\nPrint the summary of a saved scan or inventory.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	metrics, err := scanner.LoadScanResults(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	if !metrics.StartTime.IsZero() && !metrics.EndTime.IsZero() {
+		fmt.Printf("Scanned %s in %s\n", metrics.StartTime.Format("2006-01-02 15:04:05"), metrics.EndTime.Sub(metrics.StartTime).Round(time.Millisecond))
+	}
+	printSummary(&metrics, summarySections{
+		top:            *topFlag,
+		directories:    len(metrics.DuplicateDirectories)+len(metrics.SubsetDirectories) > 0,
+		nearDuplicates: len(metrics.NearDuplicates) > 0,
+		secrets:        len(metrics.Findings) > 0,
+	})
+	printTypes(metrics.TypeCount, *topFlag)
+	for _, finding := range metrics.Findings {
+		fmt.Printf("SECRET: %s:%d %s\n", finding.Path, finding.Line, finding.RuleID)
+	}
+	return exitOK
+}
+
+// printTypes lists the most common file types.
+func printTypes(counts map[string]int, limit int) {
+	if limit <= 0 || len(counts) == 0 {
+		return
+	}
+	types := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+
+	fmt.Printf("Top file types:\n")
+	for _, fileType := range scanner.TopN(types, limit) {
+		name := fileType
+		if name == "" {
+			name = "(no extension)"
+		}
+		fmt.Printf("  %8d  %s\n", counts[fileType], name)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// scanFlags holds the flags of the commands that run a scan.
+type scanFlags struct {
+	configPath string
+
+	dirs, include, exclude stringListFlag
+	rootRules              map[string]scanner.RootRules
+
+	workers      int
+	maxSize      int64
+	archiveDepth int
+	ignoreFiles  bool
+	cache        string
+
+	mode            string
+	coordinatorAddr string
+	coordinatorURL  string
+	agentID         string
+	batch           int
+
+	typeBy             string
+	prefilter          bool
+	manifest           string
+	checkpoint         string
+	checkpointInterval time.Duration
+	resume             bool
+	verify             bool
+	top                int
+	dirDuplicates      bool
+	similarity         float64
+	writeManifest      string
+	secrets            bool
+	secretRules        string
+	hash               string
+	results            string
+	httpAddr           string
+	denylist, outputs  stringListFlag
+}
+
+// registerWalk adds the flags deciding which files are read and how.
+func (f *scanFlags) registerWalk(flags *flag.FlagSet) {
+	flags.StringVar(&f.configPath, "config", "", "Config file (.json, .yaml, .yml or .toml) for the flags not given, SCANNER_* environment variables override it")
+	flags.Var(&f.dirs, "dir", "Directory to scan, repeatable (default .)")
+	flags.Var(&f.include, "include", "Only scan files matching this glob, repeatable (supports **)")
+	flags.Var(&f.exclude, "exclude", "Skip files and directories matching this glob, repeatable (supports **)")
+	flags.IntVar(&f.workers, "workers", 4, "Number of concurrent workers")
+	flags.Int64Var(&f.maxSize, "max-size", 100*1024*1024, "Maximum amount of files to scan")
+	flags.IntVar(&f.archiveDepth, "archive-depth", 0, "Hash entries inside zip/jar/war/tar/tar.gz files, recursing this many levels (0 disables)")
+	flags.BoolVar(&f.ignoreFiles, "ignore-files", true, "Skip paths listed in .gitignore files and a root .scanignore")
+	flags.StringVar(&f.cache, "cache", "", "Hash cache file used to skip unchanged files on rescans")
+}
+
+// register adds every flag of a scan.
+func (f *scanFlags) register(flags *flag.FlagSet) {
+	f.registerWalk(flags)
+
+	flags.StringVar(&f.mode, "mode", "local", "Run mode: local, coordinator or agent")
+	flags.StringVar(&f.coordinatorAddr, "coordinator-addr", ":9090", "Address the coordinator listens on for agents")
+	flags.StringVar(&f.coordinatorURL, "coordinator", "http://localhost:9090", "Coordinator URL to pull tasks from (agent mode)")
+	flags.StringVar(&f.agentID, "agent-id", "", "Agent name reported to the coordinator (defaults to host-pid)")
+	flags.IntVar(&f.batch, "batch", scanner.DefaultBatchSize, "Number of tasks an agent requests per batch")
+
+	flags.StringVar(&f.typeBy, "type-by", scanner.TypeByExtension, "Aggregate file types by \"extension\" or sniffed \"mime\" type")
+	flags.BoolVar(&f.prefilter, "prefilter", false, "Only fully hash files whose size and partial hash collide")
+	flags.StringVar(&f.manifest, "manifest", "", "Baseline manifest (sha256sum format or JSON path->digest, not Scan_Results.json) to report new, missing and changed files against")
+	flags.StringVar(&f.checkpoint, "checkpoint", "", "Periodically save progress to this file so an interrupted scan can be resumed")
+	flags.DurationVar(&f.checkpointInterval, "checkpoint-interval", scanner.DefaultCheckpointInterval, "How often to save the -checkpoint")
+	flags.BoolVar(&f.resume, "resume", false, "Continue the scan saved in -checkpoint, skipping files already collected")
+	flags.BoolVar(&f.verify, "verify", false, "Compare duplicate files byte for byte after hashing and split groups that differ")
+	flags.IntVar(&f.top, "top", scanner.DefaultTopN, "Number of most wasteful duplicate groups and directories listed in the summary")
+	flags.BoolVar(&f.dirDuplicates, "dir-duplicates", false, "Report identical directories and directories contained in others")
+	flags.Float64Var(&f.similarity, "similarity", 0, "Report groups of similar but not identical files at or above this similarity, e.g. 0.9 (0 disables)")
+	flags.StringVar(&f.writeManifest, "write-manifest", "", "Save every hashed file in sha256sum format for use as a later -manifest")
+	flags.BoolVar(&f.secrets, "secrets", false, "Detect leaked credentials while hashing")
+	flags.StringVar(&f.secretRules, "secret-rules", "", "JSON file of secret rules used instead of the built-in ones (implies -secrets)")
+	flags.StringVar(&f.hash, "hash", scanner.DefaultHashAlgorithm, "Comma separated hash algorithms, the first is used for duplicates ("+strings.Join(scanner.HashAlgorithmNames(), ", ")+")")
+	flags.StringVar(&f.results, "results", "Scan_Results.json", "File the results are saved to for diff, dedupe and report (empty disables)")
+	flags.StringVar(&f.httpAddr, "http", "127.0.0.1:8080", "Address of the HTTP API, empty disables it for scan (defaults to localhost only; use :8080 to listen on every interface)")
+	flags.Var(&f.outputs, "output", "Stream every result to [jsonl|csv|sqlite:]PATH as it is collected, repeatable (format defaults from the extension)")
+	flags.Var(&f.denylist, "denylist", "Hash list (text or CSV of MD5/SHA-1/SHA-256 digests with labels) to flag, repeatable")
+}
+
+// scanConfig builds the ScanConfig of the flags, loading every file they name.
+// It prints what went wrong and returns a non-zero exit code on failure.
+func (f *scanFlags) scanConfig() (scanner.ScanConfig, int) {
+	config := scanner.ScanConfig{
+		Directories: f.dirs,
+		WorkerCount: f.workers,
+		MaxFileSize: f.maxSize,
+		Include:     f.include,
+		Exclude:     f.exclude,
+		RootRules:   f.rootRules,
+		Prefilter:   f.prefilter,
+		TypeCountBy: f.typeBy,
+
+		ArchiveDepth:   f.archiveDepth,
+		UseIgnoreFiles: f.ignoreFiles,
+	}
+
+	var err error
+	if f.hash != "" {
+		config.HashAlgorithms, err = scanner.ParseHashAlgorithms(f.hash)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return config, exitUsage
+		}
+	}
+
+	//COMPILE SECRET DETECTION RULES
+	if f.secrets || f.secretRules != "" {
+		rules := scanner.DefaultSecretRules
+		if f.secretRules != "" {
+			rules, err = scanner.LoadSecretRules(f.secretRules)
+			if err != nil {
+				fmt.Printf("Error loading secret rules: %v\n", err)
+				return config, exitUsage
+			}
+		}
+		config.Secrets, err = scanner.NewSecretRuleSet(rules)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return config, exitUsage
+		}
+	}
+
+	//LOAD KNOWN-BAD HASHES AND MAKE SURE EVERY ALGORITHM THEY NEED IS COMPUTED
+	if len(f.denylist) > 0 {
+		config.Denylist, err = scanner.LoadDenylist(f.denylist)
+		if err != nil {
+			fmt.Printf("Error loading denylist: %v\n", err)
+			return config, exitUsage
+		}
+		for _, algorithm := range config.Denylist.Algorithms() {
+			if !containsString(config.HashAlgorithms, algorithm) {
+				config.HashAlgorithms = append(config.HashAlgorithms, algorithm)
+			}
+		}
+		fmt.Printf("Loaded %d denylisted hashes\n", config.Denylist.Len())
+	}
+
+	//LOAD BASELINE MANIFEST, WHICH NEEDS EVERY FILE FULLY HASHED
+	if f.manifest != "" {
+		manifest, err := scanner.LoadManifest(f.manifest)
+		if err != nil {
+			fmt.Printf("Error loading manifest: %v\n", err)
+			return config, exitUsage
+		}
+		for _, algorithm := range manifest.Algorithms() {
+			if !containsString(config.HashAlgorithms, algorithm) {
+				config.HashAlgorithms = append(config.HashAlgorithms, algorithm)
+			}
+		}
+		config.Baseline = scanner.NewBaseline(manifest)
+	}
+
+	if f.writeManifest != "" {
+		if len(config.HashAlgorithms) > 0 && !scanner.CanWriteManifest(config.HashAlgorithms[0]) {
+			fmt.Printf("Error: -write-manifest needs md5, sha1 or sha256 as the primary -hash\n")
+			return config, exitUsage
+		}
+		if config.Prefilter {
+			fmt.Println("Prefilter disabled: writing a manifest needs every file hashed")
+			config.Prefilter = false
+		}
+	}
+
+	//OPEN HASH CACHE SO UNCHANGED FILES ARE NOT READ AGAIN
+	if f.cache != "" {
+		cache, err := scanner.OpenHashCache(f.cache)
+		if err != nil {
+			fmt.Printf("Error opening hash cache: %v\n", err)
+			return config, exitError
+		}
+		config.HashCache = cache
+	}
+	return config, exitOK
+}
+
+// options turns every flag beyond the scan config into a Scanner option.
+func (f *scanFlags) options() []scanner.Option {
+	options := []scanner.Option{scanner.WithLogger(stdoutLogger)}
+	if f.mode == "coordinator" {
+		options = append(options, scanner.WithCoordinator(f.coordinatorAddr))
+	}
+	if f.checkpoint != "" {
+		options = append(options, scanner.WithCheckpoint(f.checkpoint, f.checkpointInterval))
+	}
+	if f.resume {
+		options = append(options, scanner.WithResume())
+	}
+	if len(f.outputs) > 0 {
+		options = append(options, scanner.WithOutputs(f.outputs...))
+	}
+	if f.verify {
+		options = append(options, scanner.WithVerify())
+	}
+	if f.dirDuplicates {
+		options = append(options, scanner.WithDirectoryDuplicates())
+	}
+	if f.similarity != 0 {
+		options = append(options, scanner.WithSimilarity(f.similarity))
+	}
+	return options
+}
+
+// runScan implements "scan [flags]" and returns the exit code.
+func runScan(args []string) int {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	var f scanFlags
+	f.register(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s scan [flags]\n\nScan the directories once, print a summary and save the results.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if errs := f.applyConfig(flags); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return exitUsage
+	}
+
+	if f.mode != "local" && f.mode != "coordinator" && f.mode != "agent" {
+		fmt.Printf("Unknown mode %q\n", f.mode)
+		return exitUsage
+	}
+
+	//AGENTS WITHOUT -dir SERVE EVERY ROOT, A LOCAL SCAN DEFAULTS TO THE CURRENT DIRECTORY
+	agentRoots := []string(f.dirs)
+	if len(f.dirs) == 0 {
+		f.dirs = stringListFlag{"."}
+	}
+
+	config, code := f.scanConfig()
+	if code != exitOK {
+		return code
+	}
+	defer closeHashCache(config.HashCache)
+
+	signalCtx, stopSignals := signalContext()
+	defer stopSignals()
+
+	//AGENTS ONLY HASH WHAT THE COORDINATOR HANDS THEM
+	if f.mode == "agent" {
+		agentConfig := scanner.AgentConfig{
+			CoordinatorURL: f.coordinatorURL,
+			AgentID:        f.agentID,
+			Roots:          agentRoots,
+			BatchSize:      f.batch,
+			Logger:         stdoutLogger,
+		}
+		if agentConfig.AgentID == "" {
+			hostname, _ := os.Hostname()
+			agentConfig.AgentID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		}
+
+		if err := scanner.RunAgent(signalCtx, agentConfig, config); err != nil {
+			fmt.Printf("Agent error: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	s, err := scanner.New(config, f.options()...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+
+	//POST /cancel AND SIGNALS BOTH CANCEL THE SCAN CONTEXT EVERY STAGE RUNS UNDER
+	ctx, cancel := context.WithCancel(signalCtx)
+	defer cancel()
+
+	//	CREATE NEW SERVER
+	var server *Server
+	if f.httpAddr != "" {
+		server = NewServer(ctx, cancel, s, f.httpAddr)
+		server.Start()
+		defer server.Stop()
+	}
+
+	metrics, err := s.Run(ctx)
+	completed := err == nil
+	switch {
+	case err == nil:
+		fmt.Println("Scan Completed Successfully")
+	case !errors.Is(err, context.Canceled):
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	case signalCtx.Err() != nil:
+		fmt.Println("Scan interrupted, saving partial results")
+	default:
+		fmt.Println("Scan cancelled by user")
+	}
+
+	if f.results != "" {
+		err = scanner.SaveResults(metrics, f.results)
+		if err != nil {
+			fmt.Printf("Error saving results: %v\n", err)
+		} else {
+			fmt.Printf("Results saved to %s\n", f.results)
+		}
+	}
+
+	if f.writeManifest != "" {
+		err = scanner.WriteManifest(metrics, f.writeManifest)
+		if err != nil {
+			fmt.Printf("Error writing manifest: %v\n", err)
+		} else {
+			fmt.Printf("Manifest saved to %s\n", f.writeManifest)
+		}
+	}
+
+	printSummary(metrics, summarySections{
+		top:            f.top,
+		directories:    f.dirDuplicates,
+		nearDuplicates: f.similarity != 0,
+		secrets:        config.Secrets != nil,
+	})
+	if config.HashCache != nil {
+		hits, misses := config.HashCache.Stats()
+		fmt.Printf("Hash cache: %d unchanged, %d hashed\n", hits, misses)
+	}
+
+	return scanExitCode(metrics, completed)
+}
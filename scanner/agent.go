@@ -0,0 +1,189 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxAgentFailures = 5
+
+// RunAgent pulls task batches from the coordinator, hashes them locally with
+// scanConfig.WorkerCount goroutines and posts the results back until the
+// coordinator reports the scan is complete or ctx is cancelled. A batch
+// interrupted by cancellation is not posted; its lease expires and the
+// coordinator hands it to another agent.
+func RunAgent(ctx context.Context, config AgentConfig, scanConfig ScanConfig) error {
+	if scanConfig.WorkerCount <= 0 {
+		scanConfig.WorkerCount = 1
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = time.Second
+	}
+	scanConfig.logger = config.Logger
+
+	baseURL := strings.TrimRight(config.CoordinatorURL, "/")
+	client := &http.Client{Timeout: defaultPollWait + 30*time.Second}
+	failures := 0
+
+	for {
+		if ctx.Err() != nil {
+			scanConfig.logf("Agent %s: stopped", config.AgentID)
+			return nil
+		}
+
+		request := TaskRequest{
+			AgentID: config.AgentID,
+			Roots:   config.Roots,
+			Max:     config.BatchSize,
+		}
+
+		var batch TaskBatch
+		err := postJSON(ctx, client, baseURL+"/agent/tasks", request, &batch)
+		if err != nil && ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			failures++
+			if failures >= maxAgentFailures {
+				return fmt.Errorf("coordinator unreachable after %d attempts: %w", failures, err)
+			}
+			scanConfig.logf("Agent %s: error fetching tasks: %v", config.AgentID, err)
+			select {
+			case <-time.After(config.RetryInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		failures = 0
+
+		if batch.Done {
+			scanConfig.logf("Agent %s: scan complete", config.AgentID)
+			return nil
+		}
+		if len(batch.Tasks) == 0 {
+			continue
+		}
+
+		//HASH WITH THE COORDINATOR'S SETTINGS SO RESULTS ARE COMPARABLE
+		if len(batch.HashAlgorithms) > 0 {
+			scanConfig.HashAlgorithms = batch.HashAlgorithms
+		}
+		scanConfig.ArchiveDepth = batch.ArchiveDepth
+		scanConfig.TypeCountBy = batch.TypeCountBy
+		scanConfig.Simhash = batch.Simhash
+		scanConfig.Secrets = nil
+		if len(batch.SecretRules) > 0 {
+			secrets, err := NewSecretRuleSet(batch.SecretRules)
+			if err != nil {
+				return fmt.Errorf("coordinator sent invalid secret rules: %w", err)
+			}
+			scanConfig.Secrets = secrets
+		}
+
+		//TASKS OUTSIDE THE AGENT'S OWN ROOTS ARE NOT READ, ONLY REPORTED BACK
+		var tasks []FileTask
+		var rejected []ScanResult
+		for _, task := range batch.Tasks {
+			if agentServes(config.Roots, task.Path) {
+				tasks = append(tasks, task)
+				continue
+			}
+			rejected = append(rejected, ScanResult{
+				Path:    task.Path,
+				Size:    task.Size,
+				ModTime: task.ModTime,
+				Mode:    task.Mode,
+				Error:   fmt.Sprintf("not under the roots of agent %s", config.AgentID),
+			})
+		}
+
+		results := processBatch(ctx, tasks, scanConfig)
+		if ctx.Err() != nil {
+			continue
+		}
+		results = append(results, rejected...)
+		response := ResultBatch{
+			BatchID: batch.ID,
+			AgentID: config.AgentID,
+			Results: results,
+		}
+
+		//A FAILED POST LEAVES THE LEASE TO EXPIRE SO THE COORDINATOR REQUEUES IT
+		err = postJSON(ctx, client, baseURL+"/agent/results", response, nil)
+		if err != nil {
+			scanConfig.logf("Agent %s: error sending results for batch %s: %v", config.AgentID, batch.ID, err)
+		}
+	}
+}
+
+// processBatch hashes the tasks of a batch concurrently, keeping results in
+// task order with any archive entries before their archive. It stops handing
+// out tasks once ctx is cancelled.
+func processBatch(ctx context.Context, tasks []FileTask, config ScanConfig) []ScanResult {
+	taskResultSets := make([][]ScanResult, len(tasks))
+	indexes := make(chan int)
+
+	var workerWaitGroup sync.WaitGroup
+	workerWaitGroup.Add(config.WorkerCount)
+	for i := 0; i < config.WorkerCount; i++ {
+		go func() {
+			defer workerWaitGroup.Done()
+			for index := range indexes {
+				taskResultSets[index] = taskResults(tasks[index], config)
+			}
+		}()
+	}
+
+feed:
+	for i := range tasks {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	workerWaitGroup.Wait()
+
+	var results []ScanResult
+	for _, taskResults := range taskResultSets {
+		results = append(results, taskResults...)
+	}
+	return results
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, response interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
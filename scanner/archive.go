@@ -0,0 +1,275 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveEntrySeparator joins an archive path and an entry name into the
+// virtual path of the entry, e.g. "app.jar!/com/x/Foo.class".
+const archiveEntrySeparator = "!/"
+
+// DefaultMaxNestedArchiveSize caps how much of a nested archive is read into
+// memory to expand it, whatever MaxFileSize allows, so a zip bomb inside an
+// archive cannot exhaust memory.
+const DefaultMaxNestedArchiveSize = 256 << 20
+
+// archiveKind tells how to open an archive by its name, or returns "" for
+// files that are not archives.
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+
+	switch filepath.Ext(lower) {
+	case ".zip", ".jar", ".war", ".ear":
+		return "zip"
+	}
+	return ""
+}
+
+// ExpandArchive hashes every entry of an archive as a virtual FileTask named
+// "archive!/entry", recursing into nested archives up to config.ArchiveDepth
+// levels. Each result carries the on-disk archive it came from.
+func ExpandArchive(task FileTask, config ScanConfig) []ScanResult {
+	kind := archiveKind(task.Path)
+	if kind == "" || config.ArchiveDepth <= 0 {
+		return nil
+	}
+
+	var results []ScanResult
+	emit := func(result ScanResult) {
+		result.Archive = task.Path
+		results = append(results, result)
+	}
+
+	var err error
+	if kind == "zip" {
+		var reader *zip.ReadCloser
+		reader, err = zip.OpenReader(task.Path)
+		if err == nil {
+			err = expandZip(&reader.Reader, task.Path, 1, config, emit)
+			reader.Close()
+		}
+	} else {
+		var file *os.File
+		file, err = os.Open(task.Path)
+		if err == nil {
+			err = expandTar(file, kind == "tar.gz", task.Path, 1, config, emit)
+			file.Close()
+		}
+	}
+
+	if err != nil {
+		config.logf("Error expanding archive %s: %v", task.Path, err)
+		emit(ScanResult{
+			Path:  task.Path + archiveEntrySeparator,
+			Error: fmt.Sprintf("expanding archive: %v", err),
+		})
+	}
+
+	return results
+}
+
+func expandZip(reader *zip.Reader, archivePath string, depth int, config ScanConfig, emit func(ScanResult)) error {
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		entry := FileTask{
+			Path:    archivePath + archiveEntrySeparator + file.Name,
+			Size:    int64(file.UncompressedSize64),
+			ModTime: file.Modified,
+			Mode:    file.Mode(),
+		}
+		if tooLarge(entry.Size, config) {
+			emit(tooLargeEntry(entry, config))
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			emit(ScanResult{Path: entry.Path, Size: entry.Size, Error: err.Error()})
+			continue
+		}
+		expandEntry(entry, content, depth, config, emit)
+		content.Close()
+	}
+	return nil
+}
+
+func expandTar(reader io.Reader, gzipped bool, archivePath string, depth int, config ScanConfig, emit func(ScanResult)) error {
+	if gzipped {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entry := FileTask{
+			Path:    archivePath + archiveEntrySeparator + header.Name,
+			Size:    header.Size,
+			ModTime: header.ModTime,
+			Mode:    header.FileInfo().Mode(),
+		}
+		if tooLarge(entry.Size, config) {
+			emit(tooLargeEntry(entry, config))
+			continue
+		}
+
+		expandEntry(entry, tarReader, depth, config, emit)
+	}
+}
+
+// expandEntry hashes one archive entry. Nested archives within the depth
+// limit are read into memory so they can be hashed and then expanded.
+func expandEntry(entry FileTask, content io.Reader, depth int, config ScanConfig, emit func(ScanResult)) {
+	result := ScanResult{
+		Path:      entry.Path,
+		Size:      entry.Size,
+		ModTime:   entry.ModTime,
+		Mode:      entry.Mode,
+		Extension: filepath.Ext(entry.Path),
+		FileType:  filepath.Ext(entry.Path),
+	}
+
+	//GUARD AGAINST ENTRIES THAT LIE ABOUT THEIR SIZE
+	if config.MaxFileSize > 0 {
+		content = &entryLimitReader{reader: content, remaining: config.MaxFileSize, limit: config.MaxFileSize}
+	}
+
+	nestedKind := archiveKind(entry.Path)
+	if nestedKind == "" || depth >= config.ArchiveDepth {
+		if err := hashContent(content, &result, config); err != nil {
+			result.Error = err.Error()
+		}
+		emit(result)
+		return
+	}
+
+	//A NESTED ARCHIVE IS BUFFERED WHOLE, SO ONE TOO LARGE FOR MEMORY IS ONLY HASHED
+	limit := nestedArchiveLimit(config)
+	if entry.Size > limit {
+		config.logf("%s: not expanded, nested archives over %d bytes are only hashed", entry.Path, limit)
+		if err := hashContent(content, &result, config); err != nil {
+			result.Error = err.Error()
+		}
+		emit(result)
+		return
+	}
+
+	data, err := io.ReadAll(&entryLimitReader{reader: content, remaining: limit, limit: limit})
+	if err != nil {
+		result.Error = err.Error()
+		emit(result)
+		return
+	}
+	if err := hashContent(bytes.NewReader(data), &result, config); err != nil {
+		result.Error = err.Error()
+	}
+	emit(result)
+
+	//RECURSE INTO THE NESTED ARCHIVE
+	if nestedKind == "zip" {
+		var zipReader *zip.Reader
+		zipReader, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err == nil {
+			err = expandZip(zipReader, entry.Path, depth+1, config, emit)
+		}
+	} else {
+		err = expandTar(bytes.NewReader(data), nestedKind == "tar.gz", entry.Path, depth+1, config, emit)
+	}
+	if err != nil {
+		emit(ScanResult{
+			Path:  entry.Path + archiveEntrySeparator,
+			Error: fmt.Sprintf("expanding archive: %v", err),
+		})
+	}
+}
+
+// nestedArchiveLimit is the most bytes of a nested archive expandEntry reads
+// into memory: MaxNestedArchiveSize, or less when MaxFileSize is smaller.
+func nestedArchiveLimit(config ScanConfig) int64 {
+	limit := config.MaxNestedArchiveSize
+	if limit <= 0 {
+		limit = DefaultMaxNestedArchiveSize
+	}
+	if config.MaxFileSize > 0 {
+		limit = min(limit, config.MaxFileSize)
+	}
+	return limit
+}
+
+func tooLarge(size int64, config ScanConfig) bool {
+	return config.MaxFileSize > 0 && size > config.MaxFileSize
+}
+
+// tooLargeEntry reports an entry skipped for its size as an error, so it
+// still shows up in the results of its archive.
+func tooLargeEntry(entry FileTask, config ScanConfig) ScanResult {
+	return ScanResult{
+		Path:    entry.Path,
+		Size:    entry.Size,
+		ModTime: entry.ModTime,
+		Mode:    entry.Mode,
+		Error:   errEntryTooLarge(config.MaxFileSize).Error(),
+	}
+}
+
+func errEntryTooLarge(limit int64) error {
+	return fmt.Errorf("entry is larger than the %d byte limit", limit)
+}
+
+// entryLimitReader fails once an entry runs past limit bytes instead of
+// cutting it short, so no digest of part of an entry is ever recorded.
+type entryLimitReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *entryLimitReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		//ONE MORE BYTE TELLS AN ENTRY OF EXACTLY limit BYTES FROM A LONGER ONE
+		var probe [1]byte
+		n, err := r.reader.Read(probe[:])
+		if n > 0 {
+			return 0, errEntryTooLarge(r.limit)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,225 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func buildZip(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range entries {
+		entryWriter, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		entryWriter.Write(content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range entries {
+		tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tarWriter.Write(content)
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	return buffer.Bytes()
+}
+
+// TestExpandArchive_Zip tests that jar entries become virtual files
+func TestExpandArchive_Zip(t *testing.T) {
+	tempDir := t.TempDir()
+	jarPath := filepath.Join(tempDir, "app.jar")
+	os.WriteFile(jarPath, buildZip(t, map[string][]byte{
+		"com/x/Foo.class":      []byte("foo bytecode"),
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0"),
+	}), 0644)
+
+	config := ScanConfig{MaxFileSize: 1024 * 1024, ArchiveDepth: 1}
+	results := ExpandArchive(FileTask{Path: jarPath}, config)
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(results))
+	}
+
+	byPath := make(map[string]ScanResult)
+	for _, result := range results {
+		byPath[result.Path] = result
+	}
+
+	foo, ok := byPath[jarPath+"!/com/x/Foo.class"]
+	if !ok {
+		t.Fatalf("Expected virtual path for Foo.class, got %v", byPath)
+	}
+	if foo.Archive != jarPath || foo.FileType != ".class" || foo.Hash == "" {
+		t.Errorf("Unexpected entry result: %+v", foo)
+	}
+
+	// Expansion is off by default
+	if results := ExpandArchive(FileTask{Path: jarPath}, ScanConfig{}); results != nil {
+		t.Errorf("Expected no entries with expansion disabled, got %d", len(results))
+	}
+}
+
+// TestExpandArchive_NestedDepth tests recursion into nested archives
+func TestExpandArchive_NestedDepth(t *testing.T) {
+	tempDir := t.TempDir()
+	innerJar := buildZip(t, map[string][]byte{"Foo.class": []byte("foo bytecode")})
+	tarPath := filepath.Join(tempDir, "release.tar.gz")
+	os.WriteFile(tarPath, buildTarGz(t, map[string][]byte{
+		"lib/inner.jar": innerJar,
+		"README":        []byte("readme"),
+	}), 0644)
+
+	shallow := ExpandArchive(FileTask{Path: tarPath}, ScanConfig{MaxFileSize: 1024 * 1024, ArchiveDepth: 1})
+	if len(shallow) != 2 {
+		t.Errorf("Expected 2 entries at depth 1, got %d", len(shallow))
+	}
+
+	deep := ExpandArchive(FileTask{Path: tarPath}, ScanConfig{MaxFileSize: 1024 * 1024, ArchiveDepth: 2})
+	if len(deep) != 3 {
+		t.Fatalf("Expected 3 entries at depth 2, got %d", len(deep))
+	}
+
+	found := false
+	for _, result := range deep {
+		if result.Path == tarPath+"!/lib/inner.jar!/Foo.class" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected nested entry path, got %v", deep)
+	}
+}
+
+// TestCollectResults_DuplicatesInsideArchives tests duplicates across two jars
+func TestCollectResults_DuplicatesInsideArchives(t *testing.T) {
+	tempDir := t.TempDir()
+	shared := []byte("shared class")
+	os.WriteFile(filepath.Join(tempDir, "a.jar"), buildZip(t, map[string][]byte{"Shared.class": shared, "A.class": []byte("a")}), 0644)
+	os.WriteFile(filepath.Join(tempDir, "b.jar"), buildZip(t, map[string][]byte{"Shared.class": shared, "B.class": []byte("b")}), 0644)
+
+	config := ScanConfig{MaxFileSize: 1024 * 1024, ArchiveDepth: 1}
+	metrics := &ScanMetrics{}
+
+	resultsChannel := make(chan ScanResult, 10)
+	for _, name := range []string{"a.jar", "b.jar"} {
+		path := filepath.Join(tempDir, name)
+		metrics.TotalFiles++
+		for _, result := range taskResults(FileTask{Path: path}, config) {
+			resultsChannel <- result
+		}
+	}
+	close(resultsChannel)
+
+	CollectResults(context.Background(), ScanConfig{}, resultsChannel, metrics, &sync.RWMutex{})
+
+	if metrics.TotalFiles != 6 || metrics.FilesPending != 0 {
+		t.Errorf("Expected 6 files and none pending, got %d and %d", metrics.TotalFiles, metrics.FilesPending)
+	}
+
+	realMetrics := CollectRealMetrics(metrics)
+	if len(realMetrics.Duplicates) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %v", realMetrics.Duplicates)
+	}
+	for _, paths := range realMetrics.Duplicates {
+		if len(paths) != 2 {
+			t.Errorf("Expected Shared.class in both jars, got %v", paths)
+		}
+	}
+}
+
+// TestExpandEntry_LargerThanLimit tests that an entry running past the size
+// limit is reported instead of hashed in part
+func TestExpandEntry_LargerThanLimit(t *testing.T) {
+	config := ScanConfig{MaxFileSize: 10, ArchiveDepth: 2, HashAlgorithms: []string{"sha256"}}
+
+	var results []ScanResult
+	emit := func(result ScanResult) { results = append(results, result) }
+	expandEntry(FileTask{Path: "a.tar!/exact.txt", Size: 10}, bytes.NewReader([]byte("0123456789")), 1, config, emit)
+	expandEntry(FileTask{Path: "a.tar!/liar.txt", Size: 4}, bytes.NewReader([]byte("0123456789abcdef")), 1, config, emit)
+	expandEntry(FileTask{Path: "a.tar!/inner.zip", Size: 4}, bytes.NewReader(bytes.Repeat([]byte("x"), 100)), 1, config, emit)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	if results[0].Error != "" || results[0].Hash == "" {
+		t.Errorf("Expected an entry of exactly the limit to be hashed, got %+v", results[0])
+	}
+	for _, result := range results[1:] {
+		if result.Error == "" || result.Hash != "" {
+			t.Errorf("Expected %s to be reported as too large and not hashed, got %+v", result.Path, result)
+		}
+	}
+}
+
+// TestExpandEntry_NestedArchiveLimit tests that nested archives are only read
+// into memory up to MaxNestedArchiveSize, even without a MaxFileSize
+func TestExpandEntry_NestedArchiveLimit(t *testing.T) {
+	inner := buildZip(t, map[string][]byte{"Foo.class": bytes.Repeat([]byte("x"), 100)})
+	config := ScanConfig{MaxFileSize: math.MaxInt64, MaxNestedArchiveSize: int64(len(inner)) - 1, ArchiveDepth: 2, HashAlgorithms: []string{"sha256"}}
+
+	var results []ScanResult
+	emit := func(result ScanResult) { results = append(results, result) }
+	expandEntry(FileTask{Path: "a.tar!/big.zip", Size: int64(len(inner))}, bytes.NewReader(inner), 1, config, emit)
+	if len(results) != 1 || results[0].Error != "" || results[0].Hash == "" {
+		t.Errorf("Expected big.zip to be hashed but not expanded, got %+v", results)
+	}
+
+	results = nil
+	expandEntry(FileTask{Path: "a.tar!/liar.zip", Size: 4}, bytes.NewReader(inner), 1, config, emit)
+	if len(results) != 1 || results[0].Error == "" || results[0].Hash != "" {
+		t.Errorf("Expected liar.zip to be reported as too large, got %+v", results)
+	}
+
+	if limit := nestedArchiveLimit(ScanConfig{MaxFileSize: math.MaxInt64}); limit != DefaultMaxNestedArchiveSize {
+		t.Errorf("Expected the default limit without MaxNestedArchiveSize, got %d", limit)
+	}
+}
+
+// TestExpandArchive_SkipsLargeEntries tests that entries over the size limit
+// are reported as errors instead of left out
+func TestExpandArchive_SkipsLargeEntries(t *testing.T) {
+	dir := t.TempDir()
+	entries := map[string][]byte{"small.txt": []byte("abc"), "big.bin": bytes.Repeat([]byte("x"), 100)}
+	zipPath := filepath.Join(dir, "app.zip")
+	tarPath := filepath.Join(dir, "app.tar.gz")
+	if err := os.WriteFile(zipPath, buildZip(t, entries), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tarPath, buildTarGz(t, entries), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ScanConfig{MaxFileSize: 10, ArchiveDepth: 1, HashAlgorithms: []string{"sha256"}}
+	for _, path := range []string{zipPath, tarPath} {
+		results := make(map[string]ScanResult)
+		for _, result := range ExpandArchive(FileTask{Path: path}, config) {
+			results[result.Path] = result
+		}
+		if small := results[path+"!/small.txt"]; small.Hash == "" {
+			t.Errorf("Expected the small entry of %s to be hashed, got %+v", path, small)
+		}
+		if big, ok := results[path+"!/big.bin"]; !ok || big.Error == "" || big.Hash != "" {
+			t.Errorf("Expected the big entry of %s to be reported as too large, got %+v", path, big)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointInterval is how often a running scan saves its checkpoint.
+const DefaultCheckpointInterval = 30 * time.Second
+
+// checkpointState is what is saved to disk. Metrics holds the raw collector
+// state, including hashes seen only once, so a resumed scan still finds
+// duplicates across the two runs.
+type checkpointState struct {
+	Directories    []string    `json:"directories"`
+	HashAlgorithms []string    `json:"hash_algorithms"`
+	Completed      []string    `json:"completed"`
+	Metrics        ScanMetrics `json:"metrics"`
+	SavedAt        time.Time   `json:"saved_at"`
+}
+
+// Checkpoint tracks which paths have been collected so an interrupted scan can
+// be resumed. Skip is only read by discovery and never changes once loaded;
+// completed is owned by the collector and guarded by the metrics mutex.
+type Checkpoint struct {
+	path      string
+	skip      map[string]bool
+	completed map[string]bool
+}
+
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{
+		path:      path,
+		skip:      make(map[string]bool),
+		completed: make(map[string]bool),
+	}
+}
+
+// Resume loads a saved checkpoint into metrics. It returns false when there is
+// no checkpoint to resume from. The checkpoint must come from a scan of the
+// same directories with the same hash algorithms.
+func (c *Checkpoint) Resume(config ScanConfig, metrics *ScanMetrics) (bool, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return false, fmt.Errorf("parsing checkpoint %s: %w", c.path, err)
+	}
+	if !slices.Equal(state.Directories, config.Directories) || !slices.Equal(state.HashAlgorithms, config.HashAlgorithms) {
+		return false, fmt.Errorf("checkpoint %s was saved by a scan of %v with %v, not %v with %v",
+			c.path, state.Directories, state.HashAlgorithms, config.Directories, config.HashAlgorithms)
+	}
+
+	for _, path := range state.Completed {
+		c.skip[path] = true
+		c.completed[path] = true
+	}
+
+	restored := state.Metrics
+	restored.HashAlgorithms = config.HashAlgorithms
+	if restored.Duplicates == nil {
+		restored.Duplicates = make(map[string][]string)
+	}
+	if restored.Hashes == nil {
+		restored.Hashes = make(map[string]map[string]string)
+	}
+	if restored.TypeCount == nil {
+		restored.TypeCount = make(map[string]int)
+	}
+
+	//FILES DISCOVERED BUT NOT COLLECTED LAST TIME ARE COUNTED AGAIN WHEN REDISCOVERED
+	restored.TotalFiles = restored.FilesScanned
+	restored.FilesPending = 0
+	restored.EndTime = time.Time{}
+	*metrics = restored
+	return true, nil
+}
+
+// Skip reports whether discovery can leave a path out because it was
+// collected before the scan was resumed.
+func (c *Checkpoint) Skip(path string) bool {
+	return c.skip[path]
+}
+
+// Complete records a collected path. It returns false when the path was
+// already collected, so results replayed after a resume are not counted twice.
+func (c *Checkpoint) Complete(path string) bool {
+	if c.completed[path] {
+		return false
+	}
+	c.completed[path] = true
+	return true
+}
+
+// Save writes the checkpoint atomically. The caller must hold the metrics
+// mutex for reading.
+func (c *Checkpoint) Save(config ScanConfig, metrics *ScanMetrics) error {
+	state := checkpointState{
+		Directories:    config.Directories,
+		HashAlgorithms: config.HashAlgorithms,
+		Completed:      make([]string, 0, len(c.completed)),
+		Metrics:        *metrics,
+		SavedAt:        time.Now(),
+	}
+	for path := range c.completed {
+		state.Completed = append(state.Completed, path)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), c.path)
+}
+
+// Remove deletes the checkpoint once the scan has completed.
+func (c *Checkpoint) Remove() error {
+	err := os.Remove(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// replayBaseline feeds the files restored from a checkpoint to the baseline,
+// which only keeps its state in memory.
+func replayBaseline(baseline *Baseline, metrics *ScanMetrics) {
+	primary := DefaultHashAlgorithm
+	if len(metrics.HashAlgorithms) > 0 {
+		primary = metrics.HashAlgorithms[0]
+	}
+
+	for hash, paths := range metrics.Duplicates {
+		hashes, ok := metrics.Hashes[hash]
+		if !ok {
+			hashes = map[string]string{primary: hash}
+		}
+		for _, path := range paths {
+			baseline.Observe(ScanResult{Path: path, Hash: hash, Hashes: hashes})
+		}
+	}
+	for _, fileError := range metrics.Errors {
+		baseline.Observe(ScanResult{Path: fileError.Path, Error: fileError.Error})
+	}
+}
+
+// saveCheckpoints saves the checkpoint every interval until stop is closed.
+func saveCheckpoints(config ScanConfig, metrics *ScanMetrics, metricsMutex *sync.RWMutex, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			metricsMutex.RLock()
+			err := config.Checkpoint.Save(config, metrics)
+			metricsMutex.RUnlock()
+			if err != nil {
+				config.logf("Error saving checkpoint: %v", err)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestCheckpoint_Resume tests that a resumed scan skips collected files and
+// ends with the same totals as an uninterrupted one
+func TestCheckpoint_Resume(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		os.WriteFile(filepath.Join(tempDir, name), []byte("same content"), 0644)
+	}
+	checkpointFile := filepath.Join(t.TempDir(), "scan.checkpoint")
+	config := ScanConfig{
+		Directories:    []string{tempDir},
+		MaxFileSize:    1024 * 1024,
+		HashAlgorithms: []string{"sha256"},
+		Checkpoint:     NewCheckpoint(checkpointFile),
+	}
+
+	//FIRST RUN IS INTERRUPTED AFTER COLLECTING a.txt
+	first := &ScanMetrics{TotalFiles: 3}
+	resultsChannel := make(chan ScanResult, 1)
+	resultsChannel <- ProcessFiles(FileTask{Path: filepath.Join(tempDir, "a.txt"), Size: 12}, config)
+	close(resultsChannel)
+	CollectResults(context.Background(), config, resultsChannel, first, &sync.RWMutex{})
+	if err := config.Checkpoint.Save(config, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//SECOND RUN RESUMES
+	config.Checkpoint = NewCheckpoint(checkpointFile)
+	metrics := &ScanMetrics{}
+	resumed, err := config.Checkpoint.Resume(config, metrics)
+	if err != nil || !resumed {
+		t.Fatalf("Expected checkpoint to resume, got %v, %v", resumed, err)
+	}
+
+	metricsMutex := &sync.RWMutex{}
+	tasksChannel := make(chan FileTask, 10)
+	DiscoverFiles(context.Background(), config, tasksChannel, metrics, metricsMutex)
+
+	resultsChannel = make(chan ScanResult, 10)
+	discovered := 0
+	for task := range tasksChannel {
+		discovered++
+		resultsChannel <- ProcessFiles(task, config)
+	}
+	//A RESULT COLLECTED BEFORE THE INTERRUPTION THAT IS SENT AGAIN IS IGNORED
+	resultsChannel <- ProcessFiles(FileTask{Path: filepath.Join(tempDir, "a.txt"), Size: 12}, config)
+	close(resultsChannel)
+	CollectResults(context.Background(), config, resultsChannel, metrics, metricsMutex)
+
+	if discovered != 2 {
+		t.Errorf("Expected 2 files discovered after resume, got %d", discovered)
+	}
+	if metrics.TotalFiles != 3 || metrics.FilesScanned != 3 || metrics.TotalBytes != 36 {
+		t.Errorf("Expected 3 files and 36 bytes, got %d/%d files and %d bytes", metrics.FilesScanned, metrics.TotalFiles, metrics.TotalBytes)
+	}
+	for _, paths := range metrics.Duplicates {
+		if len(paths) != 3 {
+			t.Errorf("Expected one group of 3 duplicates across both runs, got %v", metrics.Duplicates)
+		}
+	}
+
+	if err := config.Checkpoint.Remove(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if resumed, err := NewCheckpoint(checkpointFile).Resume(config, &ScanMetrics{}); resumed || err != nil {
+		t.Errorf("Expected nothing to resume after removal, got %v, %v", resumed, err)
+	}
+}
+
+// TestCheckpoint_ResumeMismatch tests that a checkpoint of another scan is refused
+func TestCheckpoint_ResumeMismatch(t *testing.T) {
+	checkpointFile := filepath.Join(t.TempDir(), "scan.checkpoint")
+	config := ScanConfig{Directories: []string{"/srv/a"}, HashAlgorithms: []string{"sha256"}}
+	if err := NewCheckpoint(checkpointFile).Save(config, &ScanMetrics{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	config.HashAlgorithms = []string{"md5"}
+	if _, err := NewCheckpoint(checkpointFile).Resume(config, &ScanMetrics{}); err == nil {
+		t.Error("Expected error resuming with different hash algorithms")
+	}
+}
+
+// TestReplayBaseline tests that restored files count against the manifest
+func TestReplayBaseline(t *testing.T) {
+	baseline := NewBaseline(Manifest{
+		"/srv/a.txt": {Algorithm: "md5", Digest: "5d41402abc4b2a76b9719d911017c592"},
+		"/srv/b.txt": {Algorithm: "sha256", Digest: helloSHA256},
+		"/srv/c.txt": {Algorithm: "sha256", Digest: helloSHA256},
+	})
+	metrics := &ScanMetrics{
+		HashAlgorithms: []string{"sha256", "md5"},
+		Duplicates:     map[string][]string{helloSHA256: {"/srv/a.txt", "/srv/b.txt"}},
+		Hashes:         map[string]map[string]string{helloSHA256: {"sha256": helloSHA256, "md5": "5d41402abc4b2a76b9719d911017c592"}},
+		Errors:         []FileError{{Path: "/srv/c.txt", Error: "permission denied"}},
+	}
+
+	replayBaseline(baseline, metrics)
+	report := baseline.Report()
+	if report.Matched != 2 || len(report.Unreadable) != 1 || len(report.Missing) != 0 {
+		t.Errorf("Expected 2 matched and 1 unreadable, got %+v", report)
+	}
+}
+
+// TestCheckpoint_ResumeArchive tests that an archive interrupted between its
+// entries is expanded again on resume, collecting only the missing entries
+func TestCheckpoint_ResumeArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	jarPath := filepath.Join(tempDir, "app.jar")
+	os.WriteFile(jarPath, buildZip(t, map[string][]byte{"A.class": []byte("a"), "B.class": []byte("b")}), 0644)
+	checkpointFile := filepath.Join(t.TempDir(), "scan.checkpoint")
+	config := ScanConfig{
+		Directories:    []string{tempDir},
+		MaxFileSize:    1024 * 1024,
+		ArchiveDepth:   1,
+		HashAlgorithms: []string{"sha256"},
+		Checkpoint:     NewCheckpoint(checkpointFile),
+	}
+
+	results := taskResults(FileTask{Path: jarPath}, config)
+	if len(results) != 3 || results[2].Path != jarPath {
+		t.Fatalf("Expected two entries followed by the archive, got %+v", results)
+	}
+
+	//FIRST RUN IS INTERRUPTED AFTER THE FIRST ENTRY
+	first := &ScanMetrics{TotalFiles: 1}
+	resultsChannel := make(chan ScanResult, 1)
+	resultsChannel <- results[0]
+	close(resultsChannel)
+	CollectResults(context.Background(), config, resultsChannel, first, &sync.RWMutex{})
+	if err := config.Checkpoint.Save(config, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	config.Checkpoint = NewCheckpoint(checkpointFile)
+	metrics := &ScanMetrics{}
+	if resumed, err := config.Checkpoint.Resume(config, metrics); err != nil || !resumed {
+		t.Fatalf("Expected checkpoint to resume, got %v, %v", resumed, err)
+	}
+
+	metricsMutex := &sync.RWMutex{}
+	tasksChannel := make(chan FileTask, 10)
+	DiscoverFiles(context.Background(), config, tasksChannel, metrics, metricsMutex)
+	resultsChannel = make(chan ScanResult, 10)
+	for task := range tasksChannel {
+		for _, result := range taskResults(task, config) {
+			resultsChannel <- result
+		}
+	}
+	close(resultsChannel)
+	CollectResults(context.Background(), config, resultsChannel, metrics, metricsMutex)
+
+	if metrics.FilesScanned != 3 || metrics.FilesPending != 0 {
+		t.Errorf("Expected both entries and the archive collected once, got %d scanned and %d pending", metrics.FilesScanned, metrics.FilesPending)
+	}
+}
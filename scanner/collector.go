@@ -0,0 +1,223 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CollectResults aggregates results into metrics until resultsChannel is
+// closed. When ctx is cancelled it still collects the results already waiting
+// in the channel, so the partial metrics include every file the workers
+// finished before the cancellation reached them.
+func CollectResults(ctx context.Context, config ScanConfig, resultsChannel chan ScanResult, metrics *ScanMetrics, metricsMutex *sync.RWMutex) {
+
+	//A RESUMED SCAN STARTS FROM THE METRICS RESTORED FROM ITS CHECKPOINT
+	if metrics.Duplicates == nil {
+		metrics.Duplicates = make(map[string][]string)
+	}
+	if metrics.TypeCount == nil {
+		metrics.TypeCount = make(map[string]int)
+	}
+	if metrics.Hashes == nil {
+		metrics.Hashes = make(map[string]map[string]string)
+	}
+	if metrics.Sizes == nil {
+		metrics.Sizes = make(map[string]int64)
+	}
+
+	//A SINK THAT FAILS IS DROPPED SO THE SCAN AND THE OTHER SINKS CARRY ON
+	sinks := append([]ResultSink(nil), config.Sinks...)
+	for {
+		select {
+		case result, ok := <-resultsChannel:
+			if !ok {
+				return
+			}
+			sinks = collectResult(config, sinks, result, metrics, metricsMutex)
+
+		case <-ctx.Done():
+			//DRAIN WHAT IS ALREADY BUFFERED WITHOUT WAITING FOR MORE
+			for {
+				select {
+				case result, ok := <-resultsChannel:
+					if !ok {
+						return
+					}
+					sinks = collectResult(config, sinks, result, metrics, metricsMutex)
+				default:
+					return
+				}
+			}
+		}
+	}
+
+}
+
+// collectResult adds one result to metrics and the sinks, returning the sinks
+// that are still working. Scanner callbacks run after the lock is released.
+func collectResult(config ScanConfig, sinks []ResultSink, result ScanResult, metrics *ScanMetrics, metricsMutex *sync.RWMutex) []ResultSink {
+	metricsMutex.Lock()
+	sinks, collected := aggregateResult(config, sinks, result, metrics)
+	metricsMutex.Unlock()
+
+	if collected && config.onResult != nil {
+		config.onResult(result)
+	}
+	return sinks
+}
+
+// aggregateResult does the work of collectResult under the metrics mutex. It
+// reports false for a result that was already collected before a resume.
+func aggregateResult(config ScanConfig, sinks []ResultSink, result ScanResult, metrics *ScanMetrics) ([]ResultSink, bool) {
+	//ENTRIES OF AN ARCHIVE THAT WAS INTERRUPTED COME AGAIN AFTER A RESUME
+	if config.Checkpoint != nil && !config.Checkpoint.Complete(result.Path) {
+		return sinks, false
+	}
+
+	//ARCHIVE ENTRIES ARE DISCOVERED BY THE WORKERS, NOT BY DiscoverFiles
+	if result.Archive != "" {
+		metrics.TotalFiles++
+	}
+
+	metrics.FilesScanned++
+	metrics.FilesPending = metrics.TotalFiles - metrics.FilesScanned
+	metrics.TotalBytes += result.Size
+
+	//COMPARE AGAINST THE BASELINE MANIFEST, UNREADABLE FILES INCLUDED
+	if config.Baseline != nil {
+		config.Baseline.Observe(result)
+	}
+
+	for i := 0; i < len(sinks); i++ {
+		if err := sinks[i].Write(result); err != nil {
+			config.logf("Error writing output, disabling it: %v", err)
+			sinks = append(sinks[:i], sinks[i+1:]...)
+			i--
+		}
+	}
+
+	if result.Error != "" {
+		newError := FileError{
+			Path:  result.Path,
+			Error: result.Error,
+			Time:  time.Now(),
+		}
+		metrics.Errors = append(metrics.Errors, newError)
+		return sinks, true
+	}
+
+	metrics.Findings = append(metrics.Findings, result.Findings...)
+
+	//FLAG KNOWN-BAD CONTENT
+	if config.Denylist != nil {
+		metrics.DenylistMatches = append(metrics.DenylistMatches, config.Denylist.Match(result)...)
+	}
+
+	//FILES SKIPPED BY THE PREFILTER HAVE NO HASH AND NO DUPLICATES
+	if result.Hash == "" {
+		metrics.TypeCount[result.FileType]++
+		return sinks, true
+	}
+
+	//CHECK IF HASH EXISTS IN DUPLICATE
+	if existingPaths, exists := metrics.Duplicates[result.Hash]; exists {
+		metrics.Duplicates[result.Hash] = append(existingPaths, result.Path)
+	} else {
+		metrics.Duplicates[result.Hash] = []string{result.Path}
+	}
+
+	metrics.Sizes[result.Hash] = result.Size
+
+	if config.NearDuplicates != nil {
+		config.NearDuplicates.Add(result)
+	}
+
+	//KEEP THE OTHER DIGESTS WHEN MORE THAN ONE ALGORITHM RAN
+	if len(result.Hashes) > 1 {
+		metrics.Hashes[result.Hash] = result.Hashes
+	}
+
+	metrics.TypeCount[result.FileType]++
+	return sinks, true
+}
+
+func CollectRealMetrics(metrics *ScanMetrics) ScanMetrics {
+	//CREATE A NEW METRICS
+	metricsCopy := ScanMetrics{
+		TotalFiles:     metrics.TotalFiles,
+		HashAlgorithms: metrics.HashAlgorithms,
+		TotalBytes:     metrics.TotalBytes,
+		FilesScanned:   metrics.FilesScanned,
+		FilesPending:   metrics.FilesPending,
+		StartTime:      metrics.StartTime,
+		EndTime:        metrics.EndTime,
+		Duplicates:     make(map[string][]string, len(metrics.Duplicates)),
+		TypeCount:      make(map[string]int, len(metrics.TypeCount)),
+
+		Errors:   make([]FileError, len(metrics.Errors)),
+		Findings: make([]Finding, len(metrics.Findings)),
+
+		DenylistMatches: make([]DenylistMatch, len(metrics.DenylistMatches)),
+		Baseline:        metrics.Baseline,
+
+		DuplicateDirectories: metrics.DuplicateDirectories,
+		SubsetDirectories:    metrics.SubsetDirectories,
+		NearDuplicates:       metrics.NearDuplicates,
+	}
+
+	actualDuplicates := make(map[string][]string)
+	duplicateHashes := make(map[string]map[string]string)
+	duplicateSizes := make(map[string]int64)
+	duplicateFiles := 0
+	if metrics.Verification != nil {
+		metricsCopy.Verification = make(map[string]string)
+	}
+
+	//COPY DUPLICATES DATA TO THE NEW METRICS
+	for hash, paths := range metrics.Duplicates {
+		//pathsCopy := make([]string, len(paths))
+		if len(paths) >= 2 {
+			actualDuplicates[hash] = paths
+			duplicateFiles += len(paths) - 1
+			if digests, ok := metrics.Hashes[hash]; ok {
+				duplicateHashes[hash] = digests
+			}
+			duplicateSizes[hash] = groupSize(metrics, hash)
+			if status, ok := metrics.Verification[hash]; ok {
+				metricsCopy.Verification[hash] = status
+			}
+		}
+	}
+
+	//ADD DUPLICATES AND DUPLICATES FILE COUNT
+	metricsCopy.Duplicates = actualDuplicates
+	metricsCopy.Hashes = duplicateHashes
+	metricsCopy.Sizes = duplicateSizes
+	metricsCopy.DuplicateFilesCount = duplicateFiles
+
+	//RANK THE GROUPS AND DIRECTORIES WASTING THE MOST SPACE
+	wasteGroups := DuplicateGroupsByWaste(metrics)
+	metricsCopy.Reclaimable = make(map[string]int64, len(wasteGroups))
+	for _, group := range wasteGroups {
+		metricsCopy.Reclaimable[group.Hash] = group.Reclaimable
+	}
+	metricsCopy.ReclaimableBytes = ReclaimableBytes(wasteGroups)
+	metricsCopy.TopWaste = TopN(wasteGroups, DefaultTopN)
+	metricsCopy.TopWasteDirectories = TopN(DirectoriesByWaste(wasteGroups), DefaultTopN)
+
+	//COPY ALL TYPE COUNT FROM EXISTING SERVER TYPE COUNTS TO NEW TYPE COUNTS
+	for ext, count := range metrics.TypeCount {
+		metricsCopy.TypeCount[ext] = count
+	}
+	copy(metricsCopy.Errors, metrics.Errors)
+	copy(metricsCopy.Findings, metrics.Findings)
+	copy(metricsCopy.DenylistMatches, metrics.DenylistMatches)
+
+	//RECORD END TIME
+	if !metrics.EndTime.IsZero() {
+		metricsCopy.EndTime = metrics.EndTime
+	}
+
+	return metricsCopy
+}
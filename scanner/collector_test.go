@@ -1,6 +1,8 @@
-package main
+package scanner
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -9,7 +11,7 @@ import (
 func TestCollectResults_BasicAggregation(t *testing.T) {
 	// Setup
 	resultsChannel := make(chan ScanResult, 10)
-	doneChannel := make(chan struct{})
+	ctx := context.Background()
 	metrics := &ScanMetrics{
 		StartTime:  time.Now(),
 		Duplicates: make(map[string][]string),
@@ -21,7 +23,7 @@ func TestCollectResults_BasicAggregation(t *testing.T) {
 	// Start collector
 	collectorDone := make(chan struct{})
 	go func() {
-		CollectResults(resultsChannel, doneChannel, metrics, metricsMutex)
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
 		close(collectorDone)
 	}()
 
@@ -77,7 +79,7 @@ func TestCollectResults_BasicAggregation(t *testing.T) {
 // TestCollectResults_DuplicateDetection tests duplicate file detection
 func TestCollectResults_DuplicateDetection(t *testing.T) {
 	resultsChannel := make(chan ScanResult, 10)
-	doneChannel := make(chan struct{})
+	ctx := context.Background()
 	metrics := &ScanMetrics{
 		StartTime:  time.Now(),
 		Duplicates: make(map[string][]string),
@@ -88,7 +90,7 @@ func TestCollectResults_DuplicateDetection(t *testing.T) {
 
 	collectorDone := make(chan struct{})
 	go func() {
-		CollectResults(resultsChannel, doneChannel, metrics, metricsMutex)
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
 		close(collectorDone)
 	}()
 
@@ -126,7 +128,7 @@ func TestCollectResults_DuplicateDetection(t *testing.T) {
 // TestCollectResults_ErrorHandling tests error collection
 func TestCollectResults_ErrorHandling(t *testing.T) {
 	resultsChannel := make(chan ScanResult, 10)
-	doneChannel := make(chan struct{})
+	ctx := context.Background()
 	metrics := &ScanMetrics{
 		StartTime:  time.Now(),
 		Duplicates: make(map[string][]string),
@@ -137,7 +139,7 @@ func TestCollectResults_ErrorHandling(t *testing.T) {
 
 	collectorDone := make(chan struct{})
 	go func() {
-		CollectResults(resultsChannel, doneChannel, metrics, metricsMutex)
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
 		close(collectorDone)
 
 	}()
@@ -186,7 +188,8 @@ func TestCollectResults_ErrorHandling(t *testing.T) {
 // TestCollectResults_Cancellation tests graceful cancellation
 func TestCollectResults_Cancellation(t *testing.T) {
 	resultsChannel := make(chan ScanResult, 10)
-	doneChannel := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	metrics := &ScanMetrics{
 		StartTime:  time.Now(),
 		Duplicates: make(map[string][]string),
@@ -197,7 +200,7 @@ func TestCollectResults_Cancellation(t *testing.T) {
 
 	collectorDone := make(chan struct{})
 	go func() {
-		CollectResults(resultsChannel, doneChannel, metrics, metricsMutex)
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
 		close(collectorDone)
 	}()
 
@@ -205,7 +208,7 @@ func TestCollectResults_Cancellation(t *testing.T) {
 	resultsChannel <- ScanResult{Path: "/file1.txt", Hash: "abc", FileType: ".txt", Size: 100}
 
 	// Trigger cancellation
-	close(doneChannel)
+	cancel()
 
 	// Collector should exit quickly
 	select {
@@ -221,3 +224,37 @@ func TestCollectResults_Cancellation(t *testing.T) {
 	}
 	metricsMutex.RUnlock()
 }
+
+// TestCollectResults_CancellationDrains tests that results already waiting are
+// collected when the scan is cancelled
+func TestCollectResults_CancellationDrains(t *testing.T) {
+	resultsChannel := make(chan ScanResult, 10)
+	metrics := &ScanMetrics{}
+	metricsMutex := &sync.RWMutex{}
+
+	for i := 0; i < 5; i++ {
+		resultsChannel <- ScanResult{Path: fmt.Sprintf("/file%d.txt", i), Hash: "abc", FileType: ".txt", Size: 10}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	collectorDone := make(chan struct{})
+	go func() {
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
+		close(collectorDone)
+	}()
+
+	select {
+	case <-collectorDone:
+	case <-time.After(1 * time.Second):
+		t.Fatal("Collector did not exit after cancellation")
+	}
+
+	if metrics.FilesScanned != 5 {
+		t.Errorf("Expected the 5 buffered results to be collected, got %d", metrics.FilesScanned)
+	}
+	if len(metrics.Duplicates["abc"]) != 5 {
+		t.Errorf("Expected 5 paths for abc, got %v", metrics.Duplicates["abc"])
+	}
+}
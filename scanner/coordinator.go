@@ -0,0 +1,466 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchSize    = 50
+	defaultLeaseTimeout = 2 * time.Minute
+	defaultPollWait     = 2 * time.Second
+
+	//HOW LONG A FINISHED COORDINATOR KEEPS ANSWERING AGENTS THAT HAVE NOT BEEN TOLD IT IS DONE
+	defaultDoneGrace = 10 * time.Second
+)
+
+// TaskRequest is sent by an agent asking for its next batch of work.
+type TaskRequest struct {
+	AgentID string   `json:"agent_id"`
+	Roots   []string `json:"roots"`
+	Max     int      `json:"max"`
+}
+
+// TaskBatch is a leased set of tasks handed to a single agent. Done tells the
+// agent the scan is complete and it can exit.
+type TaskBatch struct {
+	ID             string       `json:"id"`
+	Tasks          []FileTask   `json:"tasks"`
+	HashAlgorithms []string     `json:"hash_algorithms"`
+	ArchiveDepth   int          `json:"archive_depth"`
+	TypeCountBy    string       `json:"type_count_by"`
+	SecretRules    []SecretRule `json:"secret_rules,omitempty"`
+	Simhash        bool         `json:"simhash,omitempty"`
+	Done           bool         `json:"done"`
+}
+
+// ResultBatch carries the results of a leased batch back to the coordinator.
+type ResultBatch struct {
+	BatchID string       `json:"batch_id"`
+	AgentID string       `json:"agent_id"`
+	Results []ScanResult `json:"results"`
+}
+
+// errNoAgent is the error of a file under the roots of no agent.
+const errNoAgent = "no agent serves this path"
+
+type agentState struct {
+	roots []string
+	done  bool
+}
+
+type taskLease struct {
+	agentID string
+	tasks   []FileTask
+	expires time.Time
+}
+
+// Coordinator hands out FileTask batches from discovery to remote agents over
+// HTTP and feeds the ScanResults they post back into the results channel, so
+// CollectResults runs unchanged on the coordinator. Batches that are not
+// reported back before their lease expires are handed out again.
+type Coordinator struct {
+	config         ScanConfig
+	tasksChannel   chan FileTask
+	resultsChannel chan ScanResult
+	ctx            context.Context
+	leaseTimeout   time.Duration
+	pollWait       time.Duration
+	doneGrace      time.Duration
+
+	mu            sync.Mutex
+	pending       []FileTask
+	leases        map[string]*taskLease
+	nextBatchID   int
+	sending       int
+	discoveryDone bool
+	closed        bool
+
+	//ROOTS OF EVERY AGENT THAT ASKED FOR WORK AND WHETHER IT HAS BEEN TOLD THE SCAN IS DONE
+	agents         map[string]*agentState
+	agentsReleased chan struct{}
+	released       bool
+	unservedSince  time.Time
+
+	httpServer *http.Server
+}
+
+func NewCoordinator(ctx context.Context, addr string, config ScanConfig, tasksChannel chan FileTask, resultsChannel chan ScanResult) *Coordinator {
+	coordinator := &Coordinator{
+		config:         config,
+		tasksChannel:   tasksChannel,
+		resultsChannel: resultsChannel,
+		ctx:            ctx,
+		leaseTimeout:   defaultLeaseTimeout,
+		pollWait:       defaultPollWait,
+		doneGrace:      defaultDoneGrace,
+		leases:         make(map[string]*taskLease),
+		agents:         make(map[string]*agentState),
+		agentsReleased: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+
+	//REGISTER AGENT ENDPOINTS
+	mux.HandleFunc("/agent/tasks", coordinator.handleTasks)
+	mux.HandleFunc("/agent/results", coordinator.handleResults)
+
+	coordinator.httpServer = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	return coordinator
+}
+
+func (c *Coordinator) Start() {
+	go func() {
+		c.config.logf("Coordinator listening for agents on %s", c.httpServer.Addr)
+		if err := c.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			c.config.logf("Coordinator server error: %v", err)
+		}
+	}()
+}
+
+// Stop shuts the server down once every agent has been told the scan is done,
+// or after doneGrace for agents that never ask again.
+func (c *Coordinator) Stop() {
+	c.mu.Lock()
+	c.releaseIfDone()
+	c.mu.Unlock()
+
+	select {
+	case <-c.agentsReleased:
+	case <-time.After(c.doneGrace):
+		c.config.logf("Coordinator stopping before every agent was told the scan is done")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := c.httpServer.Shutdown(ctx)
+	if err != nil {
+		c.config.logf("Coordinator shutdown error: %v", err)
+	}
+}
+
+func (c *Coordinator) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid task request", http.StatusBadRequest)
+		return
+	}
+	if request.Max <= 0 {
+		request.Max = DefaultBatchSize
+	}
+
+	c.mu.Lock()
+	agent := c.agents[request.AgentID]
+	if agent == nil {
+		agent = &agentState{}
+		c.agents[request.AgentID] = agent
+	}
+	agent.roots = request.Roots
+	c.mu.Unlock()
+
+	//A CANCELLED SCAN SENDS AGENTS HOME INSTEAD OF HANDING OUT MORE WORK
+	if c.ctx.Err() != nil {
+		c.mu.Lock()
+		agent.done = true
+		c.releaseIfDone()
+		c.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TaskBatch{Done: true})
+		return
+	}
+
+	tasks := c.takeTasks(request.Roots, request.Max)
+
+	//FILES NO AGENT CAN REACH ARE REPORTED AS ERRORS INSTEAD OF HOLDING THE SCAN OPEN
+	if len(tasks) == 0 {
+		c.mu.Lock()
+		unserved := c.takeUnserved()
+		if len(unserved) > 0 {
+			c.sending++
+		}
+		c.mu.Unlock()
+		if len(unserved) > 0 {
+			results := make([]ScanResult, len(unserved))
+			for i, task := range unserved {
+				results[i] = ScanResult{Path: task.Path, Size: task.Size, ModTime: task.ModTime, Mode: task.Mode, Error: errNoAgent}
+			}
+			c.sendResults(results)
+		}
+	}
+
+	c.mu.Lock()
+	batch := TaskBatch{
+		Tasks:          tasks,
+		HashAlgorithms: c.config.HashAlgorithms,
+		ArchiveDepth:   c.config.ArchiveDepth,
+		TypeCountBy:    c.config.TypeCountBy,
+		Simhash:        c.config.Simhash,
+	}
+	if c.config.Secrets != nil {
+		batch.SecretRules = c.config.Secrets.Rules
+	}
+	if len(tasks) > 0 {
+		//LEASE THE BATCH SO IT CAN BE HANDED OUT AGAIN IF THE AGENT DISAPPEARS
+		c.nextBatchID++
+		batch.ID = strconv.Itoa(c.nextBatchID)
+		c.leases[batch.ID] = &taskLease{
+			agentID: request.AgentID,
+			tasks:   tasks,
+			expires: time.Now().Add(c.leaseTimeout),
+		}
+	} else {
+		batch.Done = c.finishIfComplete()
+		agent.done = batch.Done
+		c.releaseIfDone()
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+func (c *Coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var batch ResultBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, "Invalid result batch", http.StatusBadRequest)
+		return
+	}
+
+	//ONLY ACCEPT RESULTS FOR LIVE LEASES, EXPIRED ONES HAVE BEEN HANDED OUT AGAIN
+	c.mu.Lock()
+	if _, ok := c.leases[batch.BatchID]; !ok {
+		c.mu.Unlock()
+		http.Error(w, "Unknown or expired batch", http.StatusConflict)
+		return
+	}
+	delete(c.leases, batch.BatchID)
+	c.sending++
+	c.mu.Unlock()
+
+	if !c.sendResults(batch.Results) {
+		http.Error(w, "Scan cancelled", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendResults feeds results to the collector and reports whether all of them
+// were taken before the scan was cancelled. The caller must have counted them
+// in c.sending.
+func (c *Coordinator) sendResults(results []ScanResult) bool {
+	for _, result := range results {
+		select {
+		case c.resultsChannel <- result:
+
+		case <-c.ctx.Done():
+			c.mu.Lock()
+			c.sending--
+			c.mu.Unlock()
+			return false
+		}
+	}
+
+	c.mu.Lock()
+	c.sending--
+	c.finishIfComplete()
+	c.mu.Unlock()
+	return true
+}
+
+// takeTasks returns up to max tasks under the agent's roots, first from tasks
+// parked by earlier requests and then from discovery. Tasks the agent cannot
+// reach are parked for another agent. It waits up to pollWait for the first
+// task so idle agents do not spin.
+func (c *Coordinator) takeTasks(roots []string, max int) []FileTask {
+	c.mu.Lock()
+	c.reclaimExpiredLeases()
+	tasks := c.takePending(roots, max)
+	discoveryDone := c.discoveryDone
+	c.mu.Unlock()
+
+	timer := time.NewTimer(c.pollWait)
+	defer timer.Stop()
+
+	for len(tasks) < max && !discoveryDone {
+		var task FileTask
+		var ok bool
+
+		if len(tasks) == 0 {
+			select {
+			case task, ok = <-c.tasksChannel:
+			case <-timer.C:
+				return tasks
+			}
+		} else {
+			select {
+			case task, ok = <-c.tasksChannel:
+			default:
+				return tasks
+			}
+		}
+
+		if !ok {
+			c.mu.Lock()
+			c.discoveryDone = true
+			c.mu.Unlock()
+			break
+		}
+
+		if agentServes(roots, task.Path) {
+			tasks = append(tasks, task)
+		} else {
+			c.mu.Lock()
+			c.pending = append(c.pending, task)
+			c.mu.Unlock()
+		}
+	}
+
+	return tasks
+}
+
+// takePending removes up to max parked tasks the agent can serve. Caller must
+// hold c.mu.
+func (c *Coordinator) takePending(roots []string, max int) []FileTask {
+	var tasks []FileTask
+	remaining := c.pending[:0]
+	for _, task := range c.pending {
+		if len(tasks) < max && agentServes(roots, task.Path) {
+			tasks = append(tasks, task)
+		} else {
+			remaining = append(remaining, task)
+		}
+	}
+	c.pending = remaining
+	return tasks
+}
+
+// reclaimExpiredLeases parks the tasks of expired leases so they are handed
+// out again. Caller must hold c.mu.
+func (c *Coordinator) reclaimExpiredLeases() {
+	now := time.Now()
+	for id, lease := range c.leases {
+		if now.After(lease.expires) {
+			c.config.logf("Batch %s leased to agent %s expired, requeueing %d tasks", id, lease.agentID, len(lease.tasks))
+			c.pending = append(c.pending, lease.tasks...)
+			delete(c.leases, id)
+		}
+	}
+}
+
+// takeUnserved removes the parked tasks that no agent that has asked for work
+// serves, once discovery is done and they have waited leaseTimeout for a new
+// agent. Caller must hold c.mu.
+func (c *Coordinator) takeUnserved() []FileTask {
+	if !c.discoveryDone || len(c.agents) == 0 {
+		return nil
+	}
+
+	var unserved []FileTask
+	for _, task := range c.pending {
+		if !c.anyAgentServes(task.Path) {
+			unserved = append(unserved, task)
+		}
+	}
+	if len(unserved) == 0 {
+		c.unservedSince = time.Time{}
+		return nil
+	}
+	if c.unservedSince.IsZero() {
+		c.unservedSince = time.Now()
+		c.config.logf("%d files are under the roots of no agent, e.g. %s; waiting %v for an agent that serves them", len(unserved), unserved[0].Path, c.leaseTimeout)
+		return nil
+	}
+	if time.Since(c.unservedSince) < c.leaseTimeout {
+		return nil
+	}
+
+	remaining := c.pending[:0]
+	for _, task := range c.pending {
+		if c.anyAgentServes(task.Path) {
+			remaining = append(remaining, task)
+		}
+	}
+	c.pending = remaining
+	c.unservedSince = time.Time{}
+	c.config.logf("No agent served %d files, reporting them as errors", len(unserved))
+	return unserved
+}
+
+// anyAgentServes reports whether an agent that has asked for work serves path.
+// Caller must hold c.mu.
+func (c *Coordinator) anyAgentServes(path string) bool {
+	for _, agent := range c.agents {
+		if agentServes(agent.roots, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseIfDone lets Stop go ahead once the scan is over and every agent has
+// been told so. Caller must hold c.mu.
+func (c *Coordinator) releaseIfDone() {
+	if c.released || (!c.closed && c.ctx.Err() == nil) {
+		return
+	}
+	for _, agent := range c.agents {
+		if !agent.done {
+			return
+		}
+	}
+	close(c.agentsReleased)
+	c.released = true
+}
+
+// finishIfComplete closes the results channel once discovery has finished and
+// every task has been reported back. Caller must hold c.mu.
+func (c *Coordinator) finishIfComplete() bool {
+	if c.closed {
+		return true
+	}
+	if !c.discoveryDone || len(c.pending) > 0 || len(c.leases) > 0 || c.sending > 0 {
+		return false
+	}
+	close(c.resultsChannel)
+	c.closed = true
+	return true
+}
+
+// agentServes reports whether path lies under one of the agent's roots. An
+// agent without roots serves every path.
+func agentServes(roots []string, path string) bool {
+	if len(roots) == 0 {
+		return true
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCoordinator_AgentsWithSeparateRoots runs two agents against a loopback
+// coordinator, each serving its own directory root
+func TestCoordinator_AgentsWithSeparateRoots(t *testing.T) {
+	rootA := t.TempDir()
+	rootB := t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		os.WriteFile(filepath.Join(rootA, fmt.Sprintf("a%d.txt", i)), []byte(fmt.Sprintf("a-%d", i)), 0644)
+		os.WriteFile(filepath.Join(rootB, fmt.Sprintf("b%d.txt", i)), []byte(fmt.Sprintf("b-%d", i)), 0644)
+	}
+	// Same content under both roots
+	os.WriteFile(filepath.Join(rootA, "shared.bin"), []byte("shared"), 0644)
+	os.WriteFile(filepath.Join(rootB, "shared.bin"), []byte("shared"), 0644)
+
+	tasksChannel := make(chan FileTask, 100)
+	resultsChannel := make(chan ScanResult, 100)
+	ctx := context.Background()
+	metrics := &ScanMetrics{
+		StartTime:  time.Now(),
+		Duplicates: make(map[string][]string),
+		TypeCount:  make(map[string]int),
+		Errors:     make([]FileError, 0),
+	}
+	metricsMutex := &sync.RWMutex{}
+
+	config := ScanConfig{
+		Directories: []string{rootA, rootB},
+		MaxFileSize: 1024 * 1024,
+	}
+
+	coordinator := NewCoordinator(ctx, "", config, tasksChannel, resultsChannel)
+	coordinator.pollWait = 50 * time.Millisecond
+	server := httptest.NewServer(coordinator.httpServer.Handler)
+	defer server.Close()
+
+	go DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
+
+	collectorDone := make(chan struct{})
+	go func() {
+		CollectResults(ctx, ScanConfig{}, resultsChannel, metrics, metricsMutex)
+		close(collectorDone)
+	}()
+
+	var agentWaitGroup sync.WaitGroup
+	for _, root := range []string{rootA, rootB} {
+		agentWaitGroup.Add(1)
+		go func(root string) {
+			defer agentWaitGroup.Done()
+			err := RunAgent(ctx, AgentConfig{
+				CoordinatorURL: server.URL,
+				AgentID:        root,
+				Roots:          []string{root},
+				BatchSize:      2,
+			}, ScanConfig{WorkerCount: 2})
+			if err != nil {
+				t.Errorf("Agent for %s failed: %v", root, err)
+			}
+		}(root)
+	}
+
+	select {
+	case <-collectorDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Coordinator did not finish the scan")
+	}
+	agentWaitGroup.Wait()
+
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+
+	if metrics.FilesScanned != 12 {
+		t.Errorf("Expected 12 files scanned, got %d", metrics.FilesScanned)
+	}
+
+	sharedGroups := 0
+	for _, paths := range metrics.Duplicates {
+		if len(paths) == 2 {
+			sharedGroups++
+		}
+	}
+	if sharedGroups != 1 {
+		t.Errorf("Expected shared.bin to be detected as a duplicate across roots")
+	}
+}
+
+// TestCoordinator_RequeuesExpiredLease tests that a batch an agent never
+// reports back is handed to another agent
+func TestCoordinator_RequeuesExpiredLease(t *testing.T) {
+	tasksChannel := make(chan FileTask, 10)
+	resultsChannel := make(chan ScanResult, 10)
+	ctx := context.Background()
+
+	coordinator := NewCoordinator(ctx, "", ScanConfig{}, tasksChannel, resultsChannel)
+	coordinator.pollWait = 10 * time.Millisecond
+	coordinator.leaseTimeout = 10 * time.Millisecond
+
+	tasksChannel <- FileTask{Path: "/lost.txt", Size: 1}
+	close(tasksChannel)
+
+	first := coordinator.takeTasks(nil, 10)
+	if len(first) != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(first))
+	}
+	coordinator.leases["1"] = &taskLease{agentID: "gone", tasks: first, expires: time.Now().Add(coordinator.leaseTimeout)}
+
+	time.Sleep(20 * time.Millisecond)
+
+	second := coordinator.takeTasks(nil, 10)
+	if len(second) != 1 || second[0].Path != "/lost.txt" {
+		t.Errorf("Expected expired task to be handed out again, got %v", second)
+	}
+}
+
+func askForTasks(t *testing.T, coordinator *Coordinator, agentID string, roots []string) TaskBatch {
+	t.Helper()
+	body, _ := json.Marshal(TaskRequest{AgentID: agentID, Roots: roots})
+	recorder := httptest.NewRecorder()
+	coordinator.handleTasks(recorder, httptest.NewRequest(http.MethodPost, "/agent/tasks", bytes.NewReader(body)))
+	var batch TaskBatch
+	if err := json.NewDecoder(recorder.Body).Decode(&batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return batch
+}
+
+// TestCoordinator_StopWaitsForAgents tests that a finished coordinator keeps
+// serving until every agent has been told the scan is done
+func TestCoordinator_StopWaitsForAgents(t *testing.T) {
+	tasksChannel := make(chan FileTask)
+	resultsChannel := make(chan ScanResult)
+	coordinator := NewCoordinator(context.Background(), "", ScanConfig{}, tasksChannel, resultsChannel)
+	coordinator.pollWait = 10 * time.Millisecond
+	coordinator.doneGrace = 5 * time.Second
+
+	if batch := askForTasks(t, coordinator, "b", nil); batch.Done {
+		t.Fatal("Expected the scan to still be running")
+	}
+	close(tasksChannel)
+	if batch := askForTasks(t, coordinator, "a", nil); !batch.Done {
+		t.Fatal("Expected the scan to be done")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		coordinator.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Expected Stop to wait for agent b")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if batch := askForTasks(t, coordinator, "b", nil); !batch.Done {
+		t.Fatal("Expected agent b to be told the scan is done")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Stop to return once every agent was told")
+	}
+}
+
+// TestCoordinator_ReportsUnservedTasks tests that files under the roots of no
+// agent end up as errors instead of holding the scan open
+func TestCoordinator_ReportsUnservedTasks(t *testing.T) {
+	tasksChannel := make(chan FileTask, 10)
+	resultsChannel := make(chan ScanResult, 10)
+	coordinator := NewCoordinator(context.Background(), "", ScanConfig{}, tasksChannel, resultsChannel)
+	coordinator.pollWait = 10 * time.Millisecond
+	coordinator.leaseTimeout = 10 * time.Millisecond
+
+	tasksChannel <- FileTask{Path: "/elsewhere/x.txt", Size: 1}
+	close(tasksChannel)
+
+	if batch := askForTasks(t, coordinator, "a", []string{"/srv"}); batch.Done || len(batch.Tasks) != 0 {
+		t.Fatalf("Expected no tasks for agent a yet, got %+v", batch)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if batch := askForTasks(t, coordinator, "a", []string{"/srv"}); !batch.Done {
+		t.Fatalf("Expected the scan to be done once the file was given up on, got %+v", batch)
+	}
+
+	result := <-resultsChannel
+	if result.Path != "/elsewhere/x.txt" || result.Error != errNoAgent {
+		t.Errorf("Expected an error result for the unserved file, got %+v", result)
+	}
+	if _, ok := <-resultsChannel; ok {
+		t.Error("Expected the results channel to be closed")
+	}
+}
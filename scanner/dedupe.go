@@ -0,0 +1,306 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ways dedupe replaces a duplicate.
+const (
+	DedupeHardlink = "hardlink"
+	DedupeSymlink  = "symlink"
+	DedupeReflink  = "reflink"
+	DedupeDelete   = "delete"
+)
+
+// Ways dedupe picks the copy of a group to keep.
+const (
+	KeepOldest   = "oldest"
+	KeepShortest = "shortest"
+	KeepPriority = "priority"
+)
+
+// compareBufferSize is how much of each file sameContent reads at a time.
+const compareBufferSize = 64 * 1024
+
+type DedupeOptions struct {
+	Action string
+	Keep   string
+
+	//PATH PREFIXES IN ORDER OF PREFERENCE FOR KeepPriority
+	Priority []string
+}
+
+// DedupeGroup is the plan for one duplicate group: Keep stays as it is and
+// every path in Replace is replaced by Action.
+type DedupeGroup struct {
+	Hash    string
+	Keep    string
+	Replace []string
+	Size    int64
+
+	//PATHS LEFT ALONE, WITH THE REASON
+	Skipped map[string]string
+}
+
+type dedupeCandidate struct {
+	path string
+	info os.FileInfo
+}
+
+// PlanDedupe decides which copy of every duplicate group to keep. Archive
+// entries, files that are gone or changed size, and files already hardlinked
+// to the kept copy are skipped.
+func PlanDedupe(duplicates map[string][]string, options DedupeOptions) []DedupeGroup {
+	hashes := make([]string, 0, len(duplicates))
+	for hash, paths := range duplicates {
+		if len(paths) >= 2 {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	var groups []DedupeGroup
+	for _, hash := range hashes {
+		group := DedupeGroup{Hash: hash, Skipped: make(map[string]string)}
+
+		var candidates []dedupeCandidate
+		for _, path := range duplicates[hash] {
+			if strings.Contains(path, archiveEntrySeparator) {
+				group.Skipped[path] = "inside an archive"
+				continue
+			}
+			info, err := os.Lstat(path)
+			if err != nil {
+				group.Skipped[path] = err.Error()
+				continue
+			}
+			if !info.Mode().IsRegular() {
+				group.Skipped[path] = "not a regular file"
+				continue
+			}
+			candidates = append(candidates, dedupeCandidate{path: path, info: info})
+		}
+		if len(candidates) < 2 {
+			if len(group.Skipped) > 0 {
+				groups = append(groups, group)
+			}
+			continue
+		}
+
+		keep := candidates[chooseCanonical(candidates, options)]
+		group.Keep = keep.path
+		group.Size = keep.info.Size()
+		for _, candidate := range candidates {
+			switch {
+			case candidate.path == keep.path:
+			case candidate.info.Size() != keep.info.Size():
+				group.Skipped[candidate.path] = "size differs from the kept copy"
+			case options.Action == DedupeHardlink && os.SameFile(candidate.info, keep.info):
+				group.Skipped[candidate.path] = "already hardlinked"
+			default:
+				group.Replace = append(group.Replace, candidate.path)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// chooseCanonical returns the index of the copy to keep. Ties are broken by
+// the shorter path and then alphabetically, so plans are repeatable.
+func chooseCanonical(candidates []dedupeCandidate, options DedupeOptions) int {
+	best := 0
+	for i := 1; i < len(candidates); i++ {
+		if preferCandidate(candidates[i], candidates[best], options) {
+			best = i
+		}
+	}
+	return best
+}
+
+func preferCandidate(a, b dedupeCandidate, options DedupeOptions) bool {
+	switch options.Keep {
+	case KeepOldest:
+		if !a.info.ModTime().Equal(b.info.ModTime()) {
+			return a.info.ModTime().Before(b.info.ModTime())
+		}
+	case KeepPriority:
+		if rankA, rankB := priorityRank(a.path, options.Priority), priorityRank(b.path, options.Priority); rankA != rankB {
+			return rankA < rankB
+		}
+	}
+
+	if len(a.path) != len(b.path) {
+		return len(a.path) < len(b.path)
+	}
+	return a.path < b.path
+}
+
+// priorityRank is the index of the first prefix path is under, or
+// len(priority) when it is under none of them.
+func priorityRank(path string, priority []string) int {
+	for i, prefix := range priority {
+		prefix = filepath.Clean(prefix)
+		if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+// ApplyDedupe replaces every duplicate in the group after checking byte for
+// byte that it is identical to the kept copy. It returns the bytes reclaimed
+// and an error per path that was left alone.
+func ApplyDedupe(group DedupeGroup, action string) (int64, map[string]error) {
+	failed := make(map[string]error)
+	var reclaimed int64
+
+	for _, path := range group.Replace {
+		same, err := sameContent(group.Keep, path)
+		if err != nil {
+			failed[path] = err
+			continue
+		}
+		if !same {
+			failed[path] = errors.New("content differs from the kept copy")
+			continue
+		}
+
+		if err := replaceDuplicate(group.Keep, path, action); err != nil {
+			failed[path] = err
+			continue
+		}
+		reclaimed += group.Size
+	}
+	return reclaimed, failed
+}
+
+// replaceDuplicate swaps path for a link or clone of keep. Links and clones
+// are created next to path and renamed over it, so path is never missing.
+func replaceDuplicate(keep, path, action string) error {
+	if action == DedupeDelete {
+		return os.Remove(path)
+	}
+
+	temp, err := createReplacement(keep, path, action)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// maxTempAttempts is how many unused temporary names createReplacement tries.
+const maxTempAttempts = 10
+
+// createReplacement creates the link or clone of keep under a new name next to
+// path and returns that name. Every way of creating it fails on an existing
+// name, so a file that is already there is never touched.
+func createReplacement(keep, path, action string) (string, error) {
+	target := keep
+	if action == DedupeSymlink {
+		var err error
+		if target, err = filepath.Abs(keep); err != nil {
+			return "", err
+		}
+	}
+
+	for range maxTempAttempts {
+		temp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.dedupe-%08x", filepath.Base(path), rand.Uint32()))
+
+		var err error
+		switch action {
+		case DedupeHardlink:
+			err = os.Link(target, temp)
+		case DedupeSymlink:
+			err = os.Symlink(target, temp)
+		case DedupeReflink:
+			err = reflinkCopy(target, temp)
+		default:
+			return "", fmt.Errorf("unknown dedupe action %q", action)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return temp, err
+	}
+	return "", fmt.Errorf("no unused temporary name next to %s", path)
+}
+
+// reflinkCopy clones keep to the new file temp, removing temp again if the
+// clone fails.
+func reflinkCopy(keep, temp string) error {
+	src, err := os.Open(keep)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err := reflinkFile(src, dst); err != nil {
+		dst.Close()
+		os.Remove(temp)
+		return fmt.Errorf("reflink: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// sameContent compares two files byte for byte.
+func sameContent(pathA, pathB string) (bool, error) {
+	fileA, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+
+	fileB, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufferA := make([]byte, compareBufferSize)
+	bufferB := make([]byte, compareBufferSize)
+	for {
+		nA, errA := io.ReadFull(fileA, bufferA)
+		nB, errB := io.ReadFull(fileB, bufferB)
+		if !bytes.Equal(bufferA[:nA], bufferB[:nB]) {
+			return false, nil
+		}
+
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
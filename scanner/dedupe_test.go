@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeDuplicates(t *testing.T, dir string, content string, names ...string) []string {
+	t.Helper()
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// TestPlanDedupe_Keep tests the oldest, shortest and priority keep strategies
+func TestPlanDedupe_Keep(t *testing.T) {
+	tempDir := t.TempDir()
+	paths := writeDuplicates(t, tempDir, "same", "backup/old/report.pdf", "r.pdf", "golden/report.pdf")
+	oldest := time.Now().Add(-24 * time.Hour)
+	os.Chtimes(paths[0], oldest, oldest)
+
+	duplicates := map[string][]string{
+		"aaa": paths,
+		"bbb": {filepath.Join(tempDir, "app.jar") + archiveEntrySeparator + "Foo.class", filepath.Join(tempDir, "missing")},
+	}
+
+	tests := []struct {
+		options  DedupeOptions
+		expected string
+	}{
+		{DedupeOptions{Action: DedupeHardlink, Keep: KeepOldest}, paths[0]},
+		{DedupeOptions{Action: DedupeHardlink, Keep: KeepShortest}, paths[1]},
+		{DedupeOptions{Action: DedupeHardlink, Keep: KeepPriority, Priority: []string{filepath.Join(tempDir, "golden")}}, paths[2]},
+	}
+
+	for _, test := range tests {
+		groups := PlanDedupe(duplicates, test.options)
+		if len(groups) != 2 {
+			t.Fatalf("Expected 2 groups, got %+v", groups)
+		}
+		if groups[0].Keep != test.expected || len(groups[0].Replace) != 2 {
+			t.Errorf("Keep %s: expected to keep %s and replace 2, got %+v", test.options.Keep, test.expected, groups[0])
+		}
+		if groups[1].Keep != "" || len(groups[1].Skipped) != 2 {
+			t.Errorf("Expected archive entry and missing file to be skipped, got %+v", groups[1])
+		}
+	}
+}
+
+// TestApplyDedupe_Actions tests every action and that a file that changed
+// since the scan is left alone
+func TestApplyDedupe_Actions(t *testing.T) {
+	for _, action := range []string{DedupeHardlink, DedupeSymlink, DedupeDelete, DedupeReflink} {
+		t.Run(action, func(t *testing.T) {
+			tempDir := t.TempDir()
+			paths := writeDuplicates(t, tempDir, "duplicate content", "a.txt", "bb.txt", "ccc.txt")
+			os.WriteFile(paths[2], []byte("modified content!"), 0644)
+
+			groups := PlanDedupe(map[string][]string{"aaa": paths}, DedupeOptions{Action: action, Keep: KeepShortest})
+			reclaimed, failed := ApplyDedupe(groups[0], action)
+
+			if action == DedupeReflink && failed[paths[1]] != nil {
+				t.Skipf("Reflinks unsupported here: %v", failed[paths[1]])
+			}
+			if reclaimed != 17 || len(failed) != 1 || failed[paths[2]] == nil {
+				t.Fatalf("Expected bb.txt replaced and ccc.txt refused, got %d bytes and %v", reclaimed, failed)
+			}
+
+			keepInfo, _ := os.Stat(paths[0])
+			info, err := os.Lstat(paths[1])
+			switch action {
+			case DedupeHardlink:
+				if err != nil || !os.SameFile(info, keepInfo) {
+					t.Errorf("Expected bb.txt to be a hardlink of a.txt")
+				}
+			case DedupeSymlink:
+				if err != nil || info.Mode()&os.ModeSymlink == 0 {
+					t.Errorf("Expected bb.txt to be a symlink")
+				}
+			case DedupeDelete:
+				if !os.IsNotExist(err) {
+					t.Errorf("Expected bb.txt to be deleted, got %v", err)
+				}
+			case DedupeReflink:
+				if content, _ := os.ReadFile(paths[1]); string(content) != "duplicate content" {
+					t.Errorf("Expected reflinked content, got %q", content)
+				}
+			}
+
+			if content, _ := os.ReadFile(paths[2]); string(content) != "modified content!" {
+				t.Errorf("Expected modified file to be untouched, got %q", content)
+			}
+		})
+	}
+}
+
+// TestSameContent tests byte for byte comparison
+func TestSameContent(t *testing.T) {
+	tempDir := t.TempDir()
+	big := make([]byte, compareBufferSize*2+10)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	a := filepath.Join(tempDir, "a")
+	b := filepath.Join(tempDir, "b")
+	c := filepath.Join(tempDir, "c")
+	os.WriteFile(a, big, 0644)
+	os.WriteFile(b, big, 0644)
+	big[compareBufferSize+5] ^= 0xff
+	os.WriteFile(c, big, 0644)
+
+	if same, err := sameContent(a, b); !same || err != nil {
+		t.Errorf("Expected identical files, got %v, %v", same, err)
+	}
+	if same, err := sameContent(a, c); same || err != nil {
+		t.Errorf("Expected different files, got %v, %v", same, err)
+	}
+	os.WriteFile(c, big[:10], 0644)
+	if same, _ := sameContent(a, c); same {
+		t.Error("Expected a prefix not to match")
+	}
+}
+
+// TestReplaceDuplicate_LeavesOtherFiles tests that replacing a duplicate
+// neither touches files next to it nor leaves a temporary file behind
+func TestReplaceDuplicate_LeavesOtherFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	paths := writeDuplicates(t, tempDir, "same", "a.txt", "b.txt")
+	userFile := filepath.Join(tempDir, ".b.txt.dedupe")
+	os.WriteFile(userFile, []byte("mine"), 0644)
+
+	if err := replaceDuplicate(paths[0], paths[1], DedupeHardlink); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if data, err := os.ReadFile(userFile); err != nil || string(data) != "mine" {
+		t.Errorf("Expected %s to be left alone, got %q, %v", userFile, data, err)
+	}
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 3 {
+		t.Errorf("Expected a.txt, b.txt and the user's file only, got %v", entries)
+	}
+	keep, _ := os.Stat(paths[0])
+	replaced, _ := os.Stat(paths[1])
+	if !os.SameFile(keep, replaced) {
+		t.Error("Expected b.txt to be a hard link to a.txt")
+	}
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// digestAlgorithms maps a hex digest length to the algorithm it is read as.
+var digestAlgorithms = map[int]string{
+	32: "md5",
+	40: "sha1",
+	64: "sha256",
+}
+
+// digestAlgorithmNames is the set of algorithms digestAlgorithms can tell apart.
+var digestAlgorithmNames = map[string]bool{
+	"md5":    true,
+	"sha1":   true,
+	"sha256": true,
+}
+
+type denylistEntry struct {
+	algorithm string
+	label     string
+}
+
+// Denylist holds known-bad digests loaded from one or more hash lists.
+type Denylist struct {
+	entries map[string]denylistEntry
+}
+
+// DenylistMatch records a scanned file whose digest is on the denylist.
+type DenylistMatch struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Hash      string `json:"hash"`
+	Label     string `json:"label"`
+}
+
+// LoadDenylist reads hash lists with one digest per line, either as CSV
+// ("digest,label") or whitespace separated ("digest label", which includes
+// sha256sum/md5sum output). MD5, SHA-1 and SHA-256 digests are told apart by
+// length. Blank lines, "#" comments and a CSV header are skipped.
+func LoadDenylist(paths []string) (*Denylist, error) {
+	denylist := &Denylist{entries: make(map[string]denylistEntry)}
+
+	for _, path := range paths {
+		if err := denylist.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return denylist, nil
+}
+
+func (d *Denylist) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var digest, label string
+		if strings.Contains(line, ",") {
+			fields := strings.SplitN(line, ",", 2)
+			digest, label = fields[0], fields[1]
+		} else {
+			fields := strings.Fields(line)
+			digest = fields[0]
+			label = strings.Join(fields[1:], " ")
+		}
+		digest = strings.ToLower(strings.TrimSpace(digest))
+		label = strings.Trim(strings.TrimSpace(label), `"*`)
+
+		algorithm, ok := digestAlgorithms[len(digest)]
+		if _, err := hex.DecodeString(digest); err != nil || !ok {
+			//A NON-HEX FIRST LINE IS A CSV HEADER
+			if lineNumber == 1 {
+				continue
+			}
+			return fmt.Errorf("%s:%d: %q is not an MD5, SHA-1 or SHA-256 digest", path, lineNumber, digest)
+		}
+
+		d.entries[digest] = denylistEntry{algorithm: algorithm, label: label}
+	}
+
+	return scanner.Err()
+}
+
+// Len returns the number of digests on the denylist.
+func (d *Denylist) Len() int {
+	return len(d.entries)
+}
+
+// Algorithms returns the hash algorithms the scan must compute so every
+// digest on the list can be matched.
+func (d *Denylist) Algorithms() []string {
+	seen := make(map[string]bool)
+	for _, entry := range d.entries {
+		seen[entry.algorithm] = true
+	}
+
+	algorithms := make([]string, 0, len(seen))
+	for algorithm := range seen {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// Match returns a match for every digest of the result that is denylisted.
+func (d *Denylist) Match(result ScanResult) []DenylistMatch {
+	var matches []DenylistMatch
+	for algorithm, digest := range result.Hashes {
+		entry, ok := d.entries[digest]
+		if !ok || entry.algorithm != algorithm {
+			continue
+		}
+		matches = append(matches, DenylistMatch{
+			Path:      result.Path,
+			Algorithm: algorithm,
+			Hash:      digest,
+			Label:     entry.label,
+		})
+	}
+	return matches
+}
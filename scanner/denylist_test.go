@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestLoadDenylist_Formats tests plain text, sha256sum and CSV hash lists
+func TestLoadDenylist_Formats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	textList := filepath.Join(tempDir, "malware.txt")
+	os.WriteFile(textList, []byte(
+		"# known bad\n"+
+			"44D88612FEA8A8F36DE82E1278ABB02F EICAR test file\n"+
+			"275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f  *eicar.com\n",
+	), 0644)
+
+	csvList := filepath.Join(tempDir, "banned.csv")
+	os.WriteFile(csvList, []byte(
+		"hash,label\n"+
+			"3395856ce81f2b7382dee72602f798b642f14140,\"Banned library 1.2\"\n",
+	), 0644)
+
+	denylist, err := LoadDenylist([]string{textList, csvList})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if denylist.Len() != 3 {
+		t.Errorf("Expected 3 digests, got %d", denylist.Len())
+	}
+
+	algorithms := denylist.Algorithms()
+	if len(algorithms) != 3 || algorithms[0] != "md5" || algorithms[1] != "sha1" || algorithms[2] != "sha256" {
+		t.Errorf("Expected [md5 sha1 sha256], got %v", algorithms)
+	}
+
+	matches := denylist.Match(ScanResult{
+		Path:   "/srv/eicar.com",
+		Hashes: map[string]string{"md5": "44d88612fea8a8f36de82e1278abb02f", "sha256": "275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f"},
+	})
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %+v", matches)
+	}
+	for _, match := range matches {
+		if match.Algorithm == "md5" && match.Label != "EICAR test file" {
+			t.Errorf("Expected md5 label, got %q", match.Label)
+		}
+		if match.Algorithm == "sha256" && match.Label != "eicar.com" {
+			t.Errorf("Expected sha256sum file name as label, got %q", match.Label)
+		}
+	}
+
+	bad := filepath.Join(tempDir, "bad.txt")
+	os.WriteFile(bad, []byte("abc123\nnot-a-hash\n"), 0644)
+	if _, err := LoadDenylist([]string{bad}); err == nil {
+		t.Error("Expected error for invalid digest")
+	}
+}
+
+// TestCollectResults_DenylistMatches tests flagging in the collector
+func TestCollectResults_DenylistMatches(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "list.txt")
+	os.WriteFile(listPath, []byte("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 hello\n"), 0644)
+
+	denylist, err := LoadDenylist([]string{listPath})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resultsChannel := make(chan ScanResult, 10)
+	metrics := &ScanMetrics{}
+	resultsChannel <- ScanResult{Path: "/ok.txt", Hash: "aaa", Hashes: map[string]string{"sha256": "aaa"}}
+	resultsChannel <- ScanResult{
+		Path:   "/bad.txt",
+		Hash:   "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		Hashes: map[string]string{"sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	close(resultsChannel)
+
+	CollectResults(context.Background(), ScanConfig{Denylist: denylist}, resultsChannel, metrics, &sync.RWMutex{})
+
+	realMetrics := CollectRealMetrics(metrics)
+	if len(realMetrics.DenylistMatches) != 1 {
+		t.Fatalf("Expected 1 denylist match, got %+v", realMetrics.DenylistMatches)
+	}
+	if realMetrics.DenylistMatches[0].Path != "/bad.txt" || realMetrics.DenylistMatches[0].Label != "hello" {
+		t.Errorf("Unexpected match: %+v", realMetrics.DenylistMatches[0])
+	}
+}
+
+// TestScanner_RunDenylistWithPrefilter tests that a denylisted file with a
+// unique size is still hashed and flagged when the prefilter is on
+func TestScanner_RunDenylistWithPrefilter(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"a.txt": "same", "b.txt": "same", "evil.bin": "known bad content"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digest := sha256.Sum256([]byte("known bad content"))
+	list := filepath.Join(t.TempDir(), "bad.txt")
+	os.WriteFile(list, []byte(hex.EncodeToString(digest[:])+" evil\n"), 0644)
+
+	denylist, err := LoadDenylist([]string{list})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(ScanConfig{Directories: []string{dir}, Prefilter: true, Denylist: denylist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected scan to complete, got %v", err)
+	}
+	if len(metrics.DenylistMatches) != 1 || metrics.DenylistMatches[0].Label != "evil" {
+		t.Errorf("Expected evil.bin to be flagged, got %+v", metrics.DenylistMatches)
+	}
+}
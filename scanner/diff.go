@@ -0,0 +1,271 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ScanDiff describes how a scan changed compared with an earlier one.
+// Added, Removed, Modified, Unreadable and NotHashed are only filled in when
+// both scans are inventories, since Scan_Results.json only lists the files of
+// duplicate groups and a file leaving a group is not a file being removed.
+// Unreadable and NotHashed hold files found by both scans whose content could
+// not be compared: one scan failed to read them, or did not hash them because
+// the prefilter found nothing they could duplicate.
+type ScanDiff struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+
+	FilesCompared bool `json:"files_compared"`
+
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+
+	Unreadable []string `json:"unreadable"`
+	NotHashed  []string `json:"not_hashed"`
+
+	NewDuplicateGroups      map[string][]string `json:"new_duplicate_groups"`
+	ResolvedDuplicateGroups map[string][]string `json:"resolved_duplicate_groups"`
+
+	TypeCountDelta map[string]int `json:"type_count_delta"`
+	FilesDelta     int            `json:"files_delta"`
+	BytesDelta     int64          `json:"bytes_delta"`
+}
+
+// LoadScanResults reads a saved Scan_Results.json, or a JSON Lines or CSV
+// -output inventory, which lists every file rather than only duplicates. The
+// format is told by the content, whatever the file is named.
+func LoadScanResults(path string) (ScanMetrics, error) {
+	var metrics ScanMetrics
+	inventory, err := isInventory(path)
+	if err != nil {
+		return metrics, err
+	}
+	if inventory {
+		return inventoryMetrics(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metrics, err
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return metrics, fmt.Errorf("parsing scan results %s: %w", path, err)
+	}
+	return metrics, nil
+}
+
+// SaveResults writes the duplicate groups and totals of metrics as JSON, the
+// format LoadScanResults reads back.
+func SaveResults(metrics *ScanMetrics, fileName string) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	metricsResult := CollectRealMetrics(metrics)
+
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(metricsResult)
+}
+
+// isInventory reports whether the file at path is an inventory: CSV, or JSON
+// whose first object is a record with a path rather than saved metrics.
+func isInventory(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, err := firstByte(reader)
+	if err == io.EOF {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if first != '{' {
+		return true, nil
+	}
+
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return false, fmt.Errorf("parsing scan results %s: %w", path, err)
+	}
+	key, err := decoder.Token()
+	if err != nil {
+		return false, fmt.Errorf("parsing scan results %s: %w", path, err)
+	}
+	return key == "path", nil
+}
+
+// firstByte returns the first byte of reader that is not white space without
+// consuming it.
+func firstByte(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// inventoryMetrics rebuilds the totals, hashes and type counts of a scan from
+// its inventory. Every hashed file is kept in Duplicates, even unique ones,
+// and every record, unreadable and unhashed ones too, in Inventory.
+func inventoryMetrics(path string) (ScanMetrics, error) {
+	metrics := ScanMetrics{
+		Duplicates: make(map[string][]string),
+		TypeCount:  make(map[string]int),
+		Inventory:  make(map[string]InventoryRecord),
+	}
+	err := LoadInventory(path, func(record InventoryRecord) {
+		metrics.Inventory[record.Path] = record
+		metrics.TotalFiles++
+		metrics.FilesScanned++
+		metrics.TotalBytes += record.Size
+		if record.Error != "" {
+			return
+		}
+		if record.Hash != "" {
+			metrics.Duplicates[record.Hash] = append(metrics.Duplicates[record.Hash], record.Path)
+		}
+		metrics.TypeCount[record.Type]++
+	})
+	return metrics, err
+}
+
+// DiffScans compares two saved scans.
+func DiffScans(oldScan, newScan ScanMetrics) ScanDiff {
+	diff := ScanDiff{
+		NewDuplicateGroups:      make(map[string][]string),
+		ResolvedDuplicateGroups: make(map[string][]string),
+		TypeCountDelta:          make(map[string]int),
+		FilesDelta:              newScan.TotalFiles - oldScan.TotalFiles,
+		BytesDelta:              newScan.TotalBytes - oldScan.TotalBytes,
+	}
+
+	if oldScan.Inventory != nil && newScan.Inventory != nil {
+		diff.FilesCompared = true
+		for path, record := range newScan.Inventory {
+			oldRecord, existed := oldScan.Inventory[path]
+			if !existed {
+				diff.Added = append(diff.Added, path)
+				continue
+			}
+
+			//EXISTENCE, THEN WHETHER BOTH WERE READ, THEN SIZE AND HASH
+			switch {
+			case record.Error != "" || oldRecord.Error != "":
+				diff.Unreadable = append(diff.Unreadable, path)
+			case record.Size != oldRecord.Size:
+				diff.Modified = append(diff.Modified, path)
+			case record.Hash == "" || oldRecord.Hash == "":
+				diff.NotHashed = append(diff.NotHashed, path)
+			case record.Hash != oldRecord.Hash:
+				diff.Modified = append(diff.Modified, path)
+			}
+		}
+		for path := range oldScan.Inventory {
+			if _, exists := newScan.Inventory[path]; !exists {
+				diff.Removed = append(diff.Removed, path)
+			}
+		}
+		for _, paths := range [][]string{diff.Added, diff.Removed, diff.Modified, diff.Unreadable, diff.NotHashed} {
+			sort.Strings(paths)
+		}
+	}
+
+	for hash, paths := range newScan.Duplicates {
+		if len(paths) >= 2 && len(oldScan.Duplicates[hash]) < 2 {
+			diff.NewDuplicateGroups[hash] = paths
+		}
+	}
+	for hash, paths := range oldScan.Duplicates {
+		if len(paths) >= 2 && len(newScan.Duplicates[hash]) < 2 {
+			diff.ResolvedDuplicateGroups[hash] = paths
+		}
+	}
+
+	for fileType, count := range newScan.TypeCount {
+		if delta := count - oldScan.TypeCount[fileType]; delta != 0 {
+			diff.TypeCountDelta[fileType] = delta
+		}
+	}
+	for fileType, count := range oldScan.TypeCount {
+		if _, exists := newScan.TypeCount[fileType]; !exists {
+			diff.TypeCountDelta[fileType] = -count
+		}
+	}
+
+	return diff
+}
+
+// WriteText prints the diff for people.
+func (d ScanDiff) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Comparing %s -> %s\n", d.Old, d.New)
+	fmt.Fprintf(w, "Files: %+d\n", d.FilesDelta)
+	fmt.Fprintf(w, "Bytes: %+d\n", d.BytesDelta)
+
+	if d.FilesCompared {
+		writePathList(w, "Added", "+", d.Added)
+		writePathList(w, "Removed", "-", d.Removed)
+		writePathList(w, "Modified", "~", d.Modified)
+		writePathList(w, "Unreadable in either scan, not compared", "!", d.Unreadable)
+		writePathList(w, "Not hashed in either scan, not compared", "?", d.NotHashed)
+	} else {
+		fmt.Fprintf(w, "Added, removed and modified files: not compared, saved results only list duplicates; compare two -output inventories for those\n")
+	}
+
+	writeGroups(w, "New duplicate groups", d.NewDuplicateGroups)
+	writeGroups(w, "Resolved duplicate groups", d.ResolvedDuplicateGroups)
+
+	if len(d.TypeCountDelta) > 0 {
+		fmt.Fprintf(w, "Type counts:\n")
+		for _, fileType := range sortedKeys(d.TypeCountDelta) {
+			name := fileType
+			if name == "" {
+				name = "(none)"
+			}
+			fmt.Fprintf(w, "  %s: %+d\n", name, d.TypeCountDelta[fileType])
+		}
+	}
+}
+
+func writePathList(w io.Writer, title, marker string, paths []string) {
+	fmt.Fprintf(w, "%s: %d\n", title, len(paths))
+	for _, path := range paths {
+		fmt.Fprintf(w, "  %s %s\n", marker, path)
+	}
+}
+
+func writeGroups(w io.Writer, title string, groups map[string][]string) {
+	fmt.Fprintf(w, "%s: %d\n", title, len(groups))
+	for _, hash := range sortedKeys(groups) {
+		fmt.Fprintf(w, "  %s\n", hash)
+		for _, path := range groups[hash] {
+			fmt.Fprintf(w, "    %s\n", path)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
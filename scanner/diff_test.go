@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDiffScans tests file, duplicate group, type count and byte changes
+func TestDiffScans(t *testing.T) {
+	oldScan := ScanMetrics{
+		TotalFiles: 10,
+		TotalBytes: 1000,
+		Duplicates: map[string][]string{
+			"aaa": {"/srv/a1", "/srv/a2"},
+			"bbb": {"/srv/b1", "/srv/b2"},
+		},
+		TypeCount: map[string]int{".txt": 6, ".log": 4},
+	}
+	newScan := ScanMetrics{
+		TotalFiles: 12,
+		TotalBytes: 1500,
+		Duplicates: map[string][]string{
+			"aaa": {"/srv/a1", "/srv/a2", "/srv/a3"},
+			"ccc": {"/srv/b1", "/srv/c1"},
+		},
+		TypeCount: map[string]int{".txt": 6, ".jar": 6},
+	}
+	oldScan.Inventory = testInventory(oldScan.Duplicates)
+	newScan.Inventory = testInventory(newScan.Duplicates)
+
+	diff := DiffScans(oldScan, newScan)
+
+	if diff.FilesDelta != 2 || diff.BytesDelta != 500 {
+		t.Errorf("Expected +2 files and +500 bytes, got %+d and %+d", diff.FilesDelta, diff.BytesDelta)
+	}
+	if len(diff.Added) != 2 || diff.Added[0] != "/srv/a3" || diff.Added[1] != "/srv/c1" {
+		t.Errorf("Expected a3 and c1 added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "/srv/b2" {
+		t.Errorf("Expected b2 removed, got %v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0] != "/srv/b1" {
+		t.Errorf("Expected b1 modified, got %v", diff.Modified)
+	}
+	if _, ok := diff.NewDuplicateGroups["ccc"]; !ok || len(diff.NewDuplicateGroups) != 1 {
+		t.Errorf("Expected ccc as the only new group, got %v", diff.NewDuplicateGroups)
+	}
+	if _, ok := diff.ResolvedDuplicateGroups["bbb"]; !ok || len(diff.ResolvedDuplicateGroups) != 1 {
+		t.Errorf("Expected bbb as the only resolved group, got %v", diff.ResolvedDuplicateGroups)
+	}
+	if len(diff.TypeCountDelta) != 2 || diff.TypeCountDelta[".jar"] != 6 || diff.TypeCountDelta[".log"] != -4 {
+		t.Errorf("Expected .jar +6 and .log -4, got %v", diff.TypeCountDelta)
+	}
+
+	//SAVED RESULTS ONLY LIST DUPLICATES, SO b2 LEAVING ITS GROUP DOES NOT MEAN IT WAS REMOVED
+	oldScan.Inventory, newScan.Inventory = nil, nil
+	diff = DiffScans(oldScan, newScan)
+	if diff.FilesCompared || len(diff.Added)+len(diff.Removed)+len(diff.Modified) != 0 {
+		t.Errorf("Expected no file changes between saved results, got %+v", diff)
+	}
+	if len(diff.NewDuplicateGroups) != 1 || len(diff.ResolvedDuplicateGroups) != 1 {
+		t.Errorf("Expected duplicate group changes between saved results, got %+v", diff)
+	}
+}
+
+// TestDiffScans_UnreadableAndNotHashed tests that files one scan could not read
+// or did not hash are reported apart from added, removed and modified files
+func TestDiffScans_UnreadableAndNotHashed(t *testing.T) {
+	oldScan := ScanMetrics{Inventory: map[string]InventoryRecord{
+		"/srv/locked":  {Path: "/srv/locked", Error: "permission denied"},
+		"/srv/unique":  {Path: "/srv/unique", Size: 10},
+		"/srv/resized": {Path: "/srv/resized", Size: 10},
+	}}
+	newScan := ScanMetrics{Inventory: map[string]InventoryRecord{
+		"/srv/locked":  {Path: "/srv/locked", Size: 10, Hash: "aaa"},
+		"/srv/unique":  {Path: "/srv/unique", Size: 10, Hash: "bbb"},
+		"/srv/resized": {Path: "/srv/resized", Size: 20, Hash: "ccc"},
+	}}
+
+	diff := DiffScans(oldScan, newScan)
+
+	if len(diff.Added)+len(diff.Removed) != 0 {
+		t.Errorf("Expected no added or removed files, got %v and %v", diff.Added, diff.Removed)
+	}
+	if len(diff.Unreadable) != 1 || diff.Unreadable[0] != "/srv/locked" {
+		t.Errorf("Expected locked unreadable, got %v", diff.Unreadable)
+	}
+	if len(diff.NotHashed) != 1 || diff.NotHashed[0] != "/srv/unique" {
+		t.Errorf("Expected unique not hashed, got %v", diff.NotHashed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0] != "/srv/resized" {
+		t.Errorf("Expected resized modified, got %v", diff.Modified)
+	}
+}
+
+// testInventory builds inventory records of equal size for every file of the groups
+func testInventory(duplicates map[string][]string) map[string]InventoryRecord {
+	inventory := make(map[string]InventoryRecord)
+	for hash, paths := range duplicates {
+		for _, path := range paths {
+			inventory[path] = InventoryRecord{Path: path, Size: 100, Hash: hash}
+		}
+	}
+	return inventory
+}
+
+// TestLoadScanResults tests reading results written by SaveResults
+func TestLoadScanResults(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "Scan_Results.json")
+	metrics := &ScanMetrics{
+		TotalFiles: 3,
+		Duplicates: map[string][]string{"aaa": {"/srv/a1", "/srv/a2"}, "bbb": {"/srv/b1"}},
+		TypeCount:  map[string]int{".txt": 3},
+	}
+	if err := SaveResults(metrics, fileName); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := LoadScanResults(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded.TotalFiles != 3 || len(loaded.Duplicates) != 1 || len(loaded.Duplicates["aaa"]) != 2 {
+		t.Errorf("Unexpected results: %+v", loaded)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(bad, []byte("not json"), 0644)
+	if _, err := LoadScanResults(bad); err == nil {
+		t.Error("Expected error for invalid results file")
+	}
+}
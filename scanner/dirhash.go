@@ -0,0 +1,298 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirectoryGroup is a set of directories with identical contents: the same
+// file names with the same hashes, all the way down.
+type DirectoryGroup struct {
+	Hash        string   `json:"hash"`
+	Files       int      `json:"files"`
+	Bytes       int64    `json:"bytes"`
+	Directories []string `json:"directories"`
+}
+
+// DirectorySubset records that every file of Subset is also in Superset, at
+// the same relative path and with the same hash.
+type DirectorySubset struct {
+	Subset   string `json:"subset"`
+	Superset string `json:"superset"`
+	Files    int    `json:"files"`
+}
+
+type dirNode struct {
+	files    map[string]string
+	dirs     map[string]bool
+	hash     string
+	count    int
+	bytes    int64
+	complete bool
+}
+
+// directoryTree holds the Merkle hash of every directory under the scan roots.
+type directoryTree struct {
+	files map[string]string
+	dirs  map[string]*dirNode
+}
+
+// buildDirectoryTree hashes every directory from the names and hashes of its
+// children. A directory holding an unreadable file, or one below it, is
+// incomplete and never reported. Archive entries are not part of the tree.
+func buildDirectoryTree(metrics *ScanMetrics, roots []string) *directoryTree {
+	tree := &directoryTree{files: make(map[string]string), dirs: make(map[string]*dirNode)}
+
+	cleanRoots := make([]string, len(roots))
+	for i, root := range roots {
+		cleanRoots[i] = filepath.Clean(root)
+	}
+
+	for hash, paths := range metrics.Duplicates {
+		for _, path := range paths {
+			if strings.Contains(path, archiveEntrySeparator) {
+				continue
+			}
+			path = filepath.Clean(path)
+			tree.files[path] = hash
+			if node := tree.addDir(filepath.Dir(path), cleanRoots); node != nil {
+				node.files[filepath.Base(path)] = hash
+			}
+		}
+	}
+
+	for _, fileError := range metrics.Errors {
+		path := filepath.Clean(fileError.Path)
+		if node := tree.addDir(filepath.Dir(path), cleanRoots); node != nil {
+			node.files[filepath.Base(path)] = ""
+		}
+	}
+
+	//HASH CHILDREN BEFORE THEIR PARENTS
+	dirs := make([]string, 0, len(tree.dirs))
+	for dir := range tree.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		tree.hashDir(dir, metrics)
+	}
+	return tree
+}
+
+// addDir adds dir and its parents up to the scan root that contains it. It
+// returns nil when dir is outside every root.
+func (t *directoryTree) addDir(dir string, roots []string) *dirNode {
+	root := ""
+	for _, candidate := range roots {
+		if isWithin(dir, candidate) && len(candidate) > len(root) {
+			root = candidate
+		}
+	}
+	if root == "" {
+		return nil
+	}
+
+	node := t.dirs[dir]
+	if node == nil {
+		node = &dirNode{files: make(map[string]string), dirs: make(map[string]bool)}
+		t.dirs[dir] = node
+		if dir != root {
+			t.addDir(filepath.Dir(dir), roots).dirs[filepath.Base(dir)] = true
+		}
+	}
+	return node
+}
+
+func (t *directoryTree) hashDir(dir string, metrics *ScanMetrics) {
+	node := t.dirs[dir]
+	node.complete = true
+
+	type entry struct{ name, line string }
+	var entries []entry
+	for name, hash := range node.files {
+		if hash == "" {
+			node.complete = false
+			continue
+		}
+		node.count++
+		node.bytes += metrics.Sizes[hash]
+		entries = append(entries, entry{name, fmt.Sprintf("f\x00%s\x00%s\n", name, hash)})
+	}
+	for name := range node.dirs {
+		child := t.dirs[filepath.Join(dir, name)]
+		if !child.complete {
+			node.complete = false
+		}
+		node.count += child.count
+		node.bytes += child.bytes
+		entries = append(entries, entry{name, fmt.Sprintf("d\x00%s\x00%s\n", name, child.hash)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].line < entries[j].line })
+
+	hasher := sha256.New()
+	for _, e := range entries {
+		hasher.Write([]byte(e.line))
+	}
+	node.hash = hex.EncodeToString(hasher.Sum(nil))
+}
+
+// FindDuplicateDirectories reports identical directories and directories whose
+// files are all contained in another one. A group or subset that only follows
+// from its parents being identical or a subset is left out, so copying a
+// whole tree is reported once at its top.
+func FindDuplicateDirectories(metrics *ScanMetrics, roots []string) ([]DirectoryGroup, []DirectorySubset) {
+	tree := buildDirectoryTree(metrics, roots)
+
+	byHash := make(map[string][]string)
+	for dir, node := range tree.dirs {
+		if node.complete && node.count > 0 {
+			byHash[node.hash] = append(byHash[node.hash], dir)
+		}
+	}
+
+	var groups []DirectoryGroup
+	for hash, dirs := range byHash {
+		if len(dirs) < 2 || tree.parentsIdentical(dirs, byHash) {
+			continue
+		}
+		sort.Strings(dirs)
+		node := tree.dirs[dirs[0]]
+		groups = append(groups, DirectoryGroup{Hash: hash, Files: node.count, Bytes: node.bytes, Directories: dirs})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Bytes != groups[j].Bytes {
+			return groups[i].Bytes > groups[j].Bytes
+		}
+		return groups[i].Directories[0] < groups[j].Directories[0]
+	})
+
+	subsets := tree.findSubsets(metrics)
+	return groups, subsets
+}
+
+// parentsIdentical reports whether dirs are only identical because their
+// parents, which are distinct directories, are identical too.
+func (t *directoryTree) parentsIdentical(dirs []string, byHash map[string][]string) bool {
+	var parentHash string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		parent, ok := t.dirs[filepath.Dir(dir)]
+		if !ok || dir == filepath.Dir(dir) || !parent.complete || seen[filepath.Dir(dir)] {
+			return false
+		}
+		seen[filepath.Dir(dir)] = true
+		if parentHash == "" {
+			parentHash = parent.hash
+		} else if parent.hash != parentHash {
+			return false
+		}
+	}
+	return len(byHash[parentHash]) >= 2
+}
+
+func (t *directoryTree) findSubsets(metrics *ScanMetrics) []DirectorySubset {
+	found := make(map[[2]string]int)
+	for dir, node := range t.dirs {
+		if !node.complete || node.count == 0 {
+			continue
+		}
+
+		//ANY SUPERSET HOLDS THE SAME FILE AT THE SAME RELATIVE PATH
+		rel, hash := t.anyFile(dir)
+		for _, path := range metrics.Duplicates[hash] {
+			path = filepath.Clean(path)
+			if path == filepath.Join(dir, rel) {
+				continue
+			}
+			//A FILE AT rel ITSELF IS DIRECTLY IN THE RELATIVE ROOT "."
+			superset, ok := strings.CutSuffix(path, string(filepath.Separator)+rel)
+			if path == rel {
+				superset = "."
+			} else if !ok {
+				continue
+			}
+			other, ok := t.dirs[superset]
+			if !ok || other.count <= node.count || other.hash == node.hash {
+				continue
+			}
+			if isWithin(dir, superset) || isWithin(superset, dir) {
+				continue
+			}
+			if t.containedIn(dir, superset) {
+				found[[2]string{dir, superset}] = node.count
+			}
+		}
+	}
+
+	var subsets []DirectorySubset
+	for pair, count := range found {
+		parents := [2]string{filepath.Dir(pair[0]), filepath.Dir(pair[1])}
+		if _, implied := found[parents]; implied && filepath.Base(pair[0]) == filepath.Base(pair[1]) {
+			continue
+		}
+		subsets = append(subsets, DirectorySubset{Subset: pair[0], Superset: pair[1], Files: count})
+	}
+	sort.Slice(subsets, func(i, j int) bool {
+		if subsets[i].Files != subsets[j].Files {
+			return subsets[i].Files > subsets[j].Files
+		}
+		if subsets[i].Subset != subsets[j].Subset {
+			return subsets[i].Subset < subsets[j].Subset
+		}
+		return subsets[i].Superset < subsets[j].Superset
+	})
+	return subsets
+}
+
+// anyFile returns the relative path and hash of one file below dir.
+func (t *directoryTree) anyFile(dir string) (string, string) {
+	node := t.dirs[dir]
+	for name, hash := range node.files {
+		return name, hash
+	}
+	for name := range node.dirs {
+		if rel, hash := t.anyFile(filepath.Join(dir, name)); rel != "" {
+			return filepath.Join(name, rel), hash
+		}
+	}
+	return "", ""
+}
+
+// containedIn reports whether every file below dir is below other at the same
+// relative path with the same hash.
+func (t *directoryTree) containedIn(dir, other string) bool {
+	node := t.dirs[dir]
+	for name, hash := range node.files {
+		if t.files[filepath.Join(other, name)] != hash {
+			return false
+		}
+	}
+	for name := range node.dirs {
+		child := filepath.Join(dir, name)
+		otherChild, ok := t.dirs[filepath.Join(other, name)]
+		if !ok {
+			return false
+		}
+		if otherChild.hash == t.dirs[child].hash {
+			continue
+		}
+		if !t.containedIn(child, filepath.Join(other, name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWithin reports whether path is dir or below it. Both must be clean and
+// either both relative or both absolute, so "." holds every relative path.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
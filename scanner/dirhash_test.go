@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestFindDuplicateDirectories tests identical trees, subsets and collapsing
+// of directories that are only identical because their parents are
+func TestFindDuplicateDirectories(t *testing.T) {
+	root := filepath.FromSlash("/srv")
+	p := func(path string) string { return filepath.Join(root, filepath.FromSlash(path)) }
+
+	metrics := &ScanMetrics{
+		Duplicates: map[string][]string{
+			"h1": {p("a/x.txt"), p("b/x.txt"), p("c/x.txt"), p("d/x.txt")},
+			"h2": {p("a/sub/y.bin"), p("b/sub/y.bin"), p("c/sub/y.bin")},
+			"h3": {p("c/extra.txt")},
+			"h4": {p("a.jar") + archiveEntrySeparator + "x.txt"},
+		},
+		Sizes:  map[string]int64{"h1": 10, "h2": 100, "h3": 1},
+		Errors: []FileError{{Path: p("d/locked.bin"), Error: "permission denied"}},
+	}
+
+	groups, subsets := FindDuplicateDirectories(metrics, []string{root})
+
+	var ab *DirectoryGroup
+	for i, group := range groups {
+		if len(group.Directories) == 2 && group.Directories[0] == p("a") && group.Directories[1] == p("b") {
+			ab = &groups[i]
+		}
+		for _, dir := range group.Directories {
+			if dir == p("d") {
+				t.Errorf("Expected directory with an unreadable file to be left out, got %v", group)
+			}
+			if dir == p("a/sub") && len(group.Directories) == 2 {
+				t.Errorf("Expected a/sub and b/sub to be implied by a and b, got %v", group)
+			}
+		}
+	}
+	if ab == nil || ab.Files != 2 || ab.Bytes != 110 {
+		t.Fatalf("Expected a and b to be identical with 2 files and 110 bytes, got %+v", groups)
+	}
+
+	expected := map[string]bool{p("a"): true, p("b"): true}
+	for _, subset := range subsets {
+		if subset.Superset != p("c") || !expected[subset.Subset] {
+			t.Errorf("Unexpected subset %+v", subset)
+		}
+		delete(expected, subset.Subset)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Expected a and b to be subsets of c, got %+v", subsets)
+	}
+}
+
+// TestFindDuplicateDirectories_CopiedTree tests that a copied tree is reported
+// once at its top
+func TestFindDuplicateDirectories_CopiedTree(t *testing.T) {
+	root := filepath.FromSlash("/srv")
+	p := func(path string) string { return filepath.Join(root, filepath.FromSlash(path)) }
+
+	metrics := &ScanMetrics{
+		Duplicates: map[string][]string{
+			"h1": {p("v1/lib/a.so"), p("v1-copy/lib/a.so")},
+			"h2": {p("v1/lib/deep/b.so"), p("v1-copy/lib/deep/b.so")},
+			"h3": {p("v1/README"), p("v1-copy/README")},
+		},
+		Sizes: map[string]int64{"h1": 1, "h2": 2, "h3": 3},
+	}
+
+	groups, subsets := FindDuplicateDirectories(metrics, []string{root})
+	if len(groups) != 1 || groups[0].Directories[0] != p("v1") || groups[0].Files != 3 {
+		t.Errorf("Expected only v1 and v1-copy, got %+v", groups)
+	}
+	if len(subsets) != 0 {
+		t.Errorf("Expected no subsets, got %+v", subsets)
+	}
+}
+
+// TestFindDuplicateDirectories_RelativeRoot tests the default "." root, whose
+// files are found without a "./" prefix
+func TestFindDuplicateDirectories_RelativeRoot(t *testing.T) {
+	p := filepath.FromSlash
+	metrics := &ScanMetrics{
+		Duplicates: map[string][]string{
+			"h1": {p("a/x.txt"), p("b/x.txt"), p("c/x.txt")},
+			"h2": {p("a/sub/y.bin"), p("b/sub/y.bin"), p("c/sub/y.bin")},
+			"h3": {p("c/extra.txt")},
+			"h4": {"top.txt"},
+		},
+		Sizes: map[string]int64{"h1": 10, "h2": 100, "h3": 1, "h4": 1},
+	}
+
+	groups, subsets := FindDuplicateDirectories(metrics, []string{"."})
+	found := false
+	for _, group := range groups {
+		if len(group.Directories) == 2 && group.Directories[0] == "a" && group.Directories[1] == "b" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a and b to be identical, got %+v", groups)
+	}
+	if len(subsets) != 2 || subsets[0].Superset != "c" || subsets[1].Superset != "c" {
+		t.Errorf("Expected a and b to be subsets of c, got %+v", subsets)
+	}
+}
@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DiscoverFiles walks every directory and sends a task per file, closing
+// tasksChannel when it is done or ctx is cancelled.
+func DiscoverFiles(ctx context.Context, config ScanConfig, tasksChannel chan FileTask, metrics *ScanMetrics, metricsMutex *sync.RWMutex) {
+	defer close(tasksChannel)
+
+	//WITH PREFILTER ON, TASKS ARE HELD BACK UNTIL EVERY SIZE IS KNOWN
+	var heldTasks []FileTask
+
+	for _, dir := range config.Directories {
+		if ctx.Err() != nil {
+			return
+		}
+
+		//CHECK IF PATH IS A DIRECTORY
+		dirInfo, err := os.Stat(dir)
+		if err != nil {
+			config.logf("Error: %v", err)
+			continue
+		}
+		if !dirInfo.IsDir() {
+			config.logf("%s is not a directory", dir)
+			continue
+		}
+
+		include, exclude := rootPatterns(config, dir)
+
+		var ignores *IgnoreMatcher
+		if config.UseIgnoreFiles {
+			ignores = NewIgnoreMatcher()
+		}
+
+		//WALK DIRECTORY
+		err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			//STOP WALKING AS SOON AS THE SCAN IS CANCELLED, NOT JUST WHEN A SEND BLOCKS
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+
+			if err != nil {
+				config.logf("Error accessing %s: %v", path, err)
+				return nil
+			}
+
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			//APPLY INCLUDE/EXCLUDE PATTERNS AND IGNORE FILES RELATIVE TO THE SCAN ROOT
+			if path != dir {
+				//PRUNE EXCLUDED DIRECTORIES INSTEAD OF WALKING INTO THEM
+				if matchesAny(exclude, relPath) || (ignores != nil && ignores.Ignored(relPath, info.IsDir())) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if !info.IsDir() && len(include) > 0 && !matchesAny(include, relPath) {
+					return nil
+				}
+			}
+
+			//READ THE DIRECTORY'S IGNORE FILES BEFORE WALKING INTO IT
+			if ignores != nil && info.IsDir() {
+				if err := ignores.LoadDir(relPath, path); err != nil {
+					config.logf("Error reading ignore file in %s: %v", path, err)
+				}
+			}
+
+			//CHECK IF IT NOT A SYMLINK OR ANY KIND OF SPECIAL FILE
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			//SKIP IF FILE SIZE IS ABOVE MAX FILE SIZE
+			if info.Size() > config.MaxFileSize {
+				config.logf("Skipping %s: %d bytes is over the size limit", path, info.Size())
+				return nil
+			}
+
+			//ALREADY COLLECTED BEFORE THE SCAN WAS RESUMED
+			if config.Checkpoint != nil && config.Checkpoint.Skip(path) {
+				return nil
+			}
+
+			//ADD FILE TASK TO CHANNEL FOR PROCESSING
+			task := FileTask{
+				Path:    path,
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+				Mode:    info.Mode(),
+				Inode:   fileInode(info),
+			}
+
+			//LOCK Metric.TotalFIles to prevent concurrency issues
+			metricsMutex.Lock()
+			metrics.TotalFiles++
+			metricsMutex.Unlock()
+
+			if config.Prefilter {
+				heldTasks = append(heldTasks, task)
+				return nil
+			}
+
+			//SEND TASK THROUGH TASK CHANNEL
+			select {
+			case tasksChannel <- task:
+
+			case <-ctx.Done():
+				return filepath.SkipAll
+			}
+			return nil
+		})
+	}
+
+	if config.Prefilter && ctx.Err() == nil {
+		tasks, err := PrefilterTasks(ctx, heldTasks, config.WorkerCount)
+		if err != nil {
+			return
+		}
+		for _, task := range tasks {
+			select {
+			case tasksChannel <- task:
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+}
@@ -1,6 +1,7 @@
-package main
+package scanner
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,7 +29,7 @@ func TestDiscoverFiles_BasicDiscovery(t *testing.T) {
 
 	// Setup channels
 	tasksChannel := make(chan FileTask, 10)
-	doneChannel := make(chan struct{})
+	ctx := context.Background()
 	metrics := &ScanMetrics{
 		Duplicates: make(map[string][]string),
 		TypeCount:  make(map[string]int),
@@ -41,7 +42,7 @@ func TestDiscoverFiles_BasicDiscovery(t *testing.T) {
 		MaxFileSize: 1024 * 1024,
 	}
 
-	go DiscoverFiles(config, tasksChannel, doneChannel, metrics, metricsMutex)
+	go DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
 
 	// Collect discovered tasks
 	var discoveredTasks []FileTask
@@ -74,7 +75,7 @@ func TestDiscoverFiles_MaxSizeFilter(t *testing.T) {
 	os.WriteFile(largeFile, make([]byte, 1024*1024), 0644) // 1MB
 
 	tasksChannel := make(chan FileTask, 10)
-	doneChannel := make(chan struct{})
+	ctx := context.Background()
 	metrics := &ScanMetrics{
 		Duplicates: make(map[string][]string),
 		TypeCount:  make(map[string]int),
@@ -86,7 +87,7 @@ func TestDiscoverFiles_MaxSizeFilter(t *testing.T) {
 		MaxFileSize: 1000, // Only 1000 bytes max
 	}
 
-	go DiscoverFiles(config, tasksChannel, doneChannel, metrics, metricsMutex)
+	go DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
 
 	var discoveredTasks []FileTask
 	for task := range tasksChannel {
@@ -114,7 +115,8 @@ func TestDiscoverFiles_Cancellation(t *testing.T) {
 	}
 
 	tasksChannel := make(chan FileTask, 10)
-	doneChannel := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	metrics := &ScanMetrics{
 		Duplicates: make(map[string][]string),
 		TypeCount:  make(map[string]int),
@@ -128,12 +130,12 @@ func TestDiscoverFiles_Cancellation(t *testing.T) {
 
 	discoveryDone := make(chan struct{})
 	go func() {
-		DiscoverFiles(config, tasksChannel, doneChannel, metrics, metricsMutex)
+		DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
 		close(discoveryDone)
 	}()
 
 	// Cancel immediately
-	close(doneChannel)
+	cancel()
 
 	// Discovery should exit quickly
 	select {
@@ -143,3 +145,99 @@ func TestDiscoverFiles_Cancellation(t *testing.T) {
 		t.Error("Discovery did not exit after cancellation")
 	}
 }
+
+// TestDiscoverFiles_IncludeExclude tests glob filtering and directory pruning
+func TestDiscoverFiles_IncludeExclude(t *testing.T) {
+	tempDir := t.TempDir()
+	otherDir := t.TempDir()
+
+	files := []string{
+		"app/main.go",
+		"app/main_test.go",
+		"app/node_modules/lib/index.go",
+		"docs/readme.md",
+		".git/objects/ab.go",
+	}
+	for _, f := range files {
+		fullPath := filepath.Join(tempDir, f)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte("test"), 0644)
+	}
+	os.WriteFile(filepath.Join(otherDir, "extra.go"), []byte("test"), 0644)
+
+	tasksChannel := make(chan FileTask, 10)
+	ctx := context.Background()
+	metrics := &ScanMetrics{
+		Duplicates: make(map[string][]string),
+		TypeCount:  make(map[string]int),
+	}
+	metricsMutex := &sync.RWMutex{}
+
+	config := ScanConfig{
+		Directories: []string{tempDir, otherDir},
+		MaxFileSize: 1024 * 1024,
+		Include:     []string{"**/*.go"},
+		Exclude:     []string{"node_modules", ".git", "*_test.go"},
+	}
+
+	go DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
+
+	found := make(map[string]bool)
+	for task := range tasksChannel {
+		found[filepath.Base(task.Path)] = true
+	}
+
+	if len(found) != 2 || !found["main.go"] || !found["extra.go"] {
+		t.Errorf("Expected only main.go and extra.go, got %v", found)
+	}
+}
+
+// TestDiscoverFiles_RootRules tests patterns that only apply under one root
+func TestDiscoverFiles_RootRules(t *testing.T) {
+	tempDir := t.TempDir()
+	otherDir := t.TempDir()
+
+	for _, f := range []string{"main.go", "notes.md", "build/out.go"} {
+		for _, dir := range []string{tempDir, otherDir} {
+			fullPath := filepath.Join(dir, f)
+			os.MkdirAll(filepath.Dir(fullPath), 0755)
+			os.WriteFile(fullPath, []byte("test"), 0644)
+		}
+	}
+
+	tasksChannel := make(chan FileTask, 10)
+	metrics := &ScanMetrics{
+		Duplicates: make(map[string][]string),
+		TypeCount:  make(map[string]int),
+	}
+
+	config := ScanConfig{
+		Directories: []string{tempDir, otherDir},
+		MaxFileSize: 1024 * 1024,
+		Include:     []string{"*.go"},
+		RootRules: map[string]RootRules{
+			otherDir: {Include: []string{"*.md"}, Exclude: []string{"build"}},
+		},
+	}
+
+	go DiscoverFiles(context.Background(), config, tasksChannel, metrics, &sync.RWMutex{})
+
+	found := make(map[string]bool)
+	for task := range tasksChannel {
+		found[task.Path] = true
+	}
+
+	expected := []string{
+		filepath.Join(tempDir, "main.go"),
+		filepath.Join(tempDir, "build/out.go"),
+		filepath.Join(otherDir, "notes.md"),
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, found)
+	}
+	for _, path := range expected {
+		if !found[path] {
+			t.Errorf("Expected %s to be discovered, got %v", path, found)
+		}
+	}
+}
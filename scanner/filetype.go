@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is how many leading bytes of a file are kept for type detection.
+const sniffLen = 512
+
+const (
+	TypeByExtension = "extension"
+	TypeByMime      = "mime"
+)
+
+type magicSignature struct {
+	offset int
+	magic  []byte
+	mime   string
+}
+
+// magicSignatures covers binary and archive formats http.DetectContentType
+// does not know about or reports as application/octet-stream.
+var magicSignatures = []magicSignature{
+	{0, []byte("\x7fELF"), "application/x-elf"},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{0, []byte{0xfe, 0xed, 0xfa, 0xce}, "application/x-mach-binary"},
+	{0, []byte{0xfe, 0xed, 0xfa, 0xcf}, "application/x-mach-binary"},
+	{0, []byte{0xce, 0xfa, 0xed, 0xfe}, "application/x-mach-binary"},
+	{0, []byte{0xcf, 0xfa, 0xed, 0xfe}, "application/x-mach-binary"},
+	{0, []byte("PK\x03\x04"), "application/zip"},
+	{0, []byte("PK\x05\x06"), "application/zip"},
+	{0, []byte("\x1f\x8b"), "application/gzip"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte{0x28, 0xb5, 0x2f, 0xfd}, "application/zstd"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{257, []byte("ustar"), "application/x-tar"},
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("\x00asm"), "application/wasm"},
+	{0, []byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{0, []byte("#!"), "text/x-script"},
+}
+
+// DetectMimeType identifies content by its leading bytes, falling back to
+// http.DetectContentType. Parameters such as charset are dropped so results
+// aggregate cleanly.
+func DetectMimeType(header []byte) string {
+	if len(header) == 0 {
+		return "application/x-empty"
+	}
+
+	//0xCAFEBABE IS BOTH A JAVA CLASS AND A UNIVERSAL MACH-O BINARY
+	if len(header) >= 8 && bytes.HasPrefix(header, []byte{0xca, 0xfe, 0xba, 0xbe}) {
+		if binary.BigEndian.Uint16(header[6:8]) >= 45 {
+			return "application/java-vm"
+		}
+		return "application/x-mach-binary"
+	}
+
+	for _, signature := range magicSignatures {
+		end := signature.offset + len(signature.magic)
+		if len(header) >= end && bytes.Equal(header[signature.offset:end], signature.magic) {
+			return signature.mime
+		}
+	}
+
+	mimeType := http.DetectContentType(header)
+	if index := strings.Index(mimeType, ";"); index >= 0 {
+		mimeType = mimeType[:index]
+	}
+	return mimeType
+}
+
+// typeKey picks the key TypeCount aggregates a result under.
+func typeKey(result ScanResult, config ScanConfig) string {
+	if config.TypeCountBy == TypeByMime && result.MimeType != "" {
+		return result.MimeType
+	}
+	return result.Extension
+}
+
+// headerCapture is an io.Writer that keeps the first sniffLen bytes written
+// to it, so type detection shares the hashing read pass.
+type headerCapture struct {
+	data []byte
+}
+
+func (h *headerCapture) Write(p []byte) (int, error) {
+	if remaining := sniffLen - len(h.data); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		h.data = append(h.data, p[:remaining]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	tarHeader := make([]byte, 512)
+	copy(tarHeader[257:], "ustar")
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"ELF binary", []byte("\x7fELF\x02\x01\x01"), "application/x-elf"},
+		{"PE binary", []byte("MZ\x90\x00"), "application/vnd.microsoft.portable-executable"},
+		{"Mach-O 64-bit", []byte{0xcf, 0xfa, 0xed, 0xfe, 0x07, 0x00}, "application/x-mach-binary"},
+		{"Java class", []byte{0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x41}, "application/java-vm"},
+		{"Universal Mach-O", []byte{0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x02}, "application/x-mach-binary"},
+		{"Zip archive", []byte("PK\x03\x04\x14\x00"), "application/zip"},
+		{"Gzip", []byte("\x1f\x8b\x08\x00"), "application/gzip"},
+		{"Tar", tarHeader, "application/x-tar"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00"), "image/png"},
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"Plain text falls back", []byte("hello world\n"), "text/plain"},
+		{"Empty", []byte{}, "application/x-empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMimeType(tt.header); got != tt.want {
+				t.Errorf("DetectMimeType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProcessFile_TypeByMime tests that renamed and extension-less files are classified by content
+func TestProcessFile_TypeByMime(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		filename string
+		content  []byte
+		wantMime string
+	}{
+		{"mybinary", []byte("\x7fELF\x02\x01\x01\x00rest of binary"), "application/x-elf"},
+		{"image.txt", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+	}
+
+	for _, tt := range tests {
+		testFile := filepath.Join(tempDir, tt.filename)
+		os.WriteFile(testFile, tt.content, 0644)
+
+		task := FileTask{Path: testFile, Size: int64(len(tt.content))}
+		result := ProcessFiles(task, ScanConfig{TypeCountBy: TypeByMime})
+
+		if result.MimeType != tt.wantMime || result.FileType != tt.wantMime {
+			t.Errorf("%s: expected mime and file type %s, got %q and %q", tt.filename, tt.wantMime, result.MimeType, result.FileType)
+		}
+		if result.Extension != filepath.Ext(tt.filename) {
+			t.Errorf("%s: expected extension %q, got %q", tt.filename, filepath.Ext(tt.filename), result.Extension)
+		}
+
+		// By default TypeCount still aggregates by extension
+		result = ProcessFiles(task, ScanConfig{})
+		if result.FileType != filepath.Ext(tt.filename) || result.MimeType != tt.wantMime {
+			t.Errorf("%s: expected extension file type with mime recorded, got %+v", tt.filename, result)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ValidatePatterns checks that every include/exclude pattern is a valid glob.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// rootPatterns returns the include and exclude patterns that apply under dir.
+func rootPatterns(config ScanConfig, dir string) ([]string, []string) {
+	rules, ok := config.RootRules[dir]
+	if !ok {
+		return config.Include, config.Exclude
+	}
+	include := config.Include
+	if len(rules.Include) > 0 {
+		include = rules.Include
+	}
+	return include, append(slices.Clip(config.Exclude), rules.Exclude...)
+}
+
+// matchesAny reports whether relPath, a slash separated path relative to the
+// scan root, matches one of the patterns. Patterns without a slash are matched
+// against the base name, so "node_modules" or "*.log" apply at any depth.
+func matchesAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(relPath)
+		}
+		if matched, _ := doublestar.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
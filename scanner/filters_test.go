@@ -0,0 +1,32 @@
+package scanner
+
+import "testing"
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{"Base name at any depth", []string{"node_modules"}, "a/b/node_modules", true},
+		{"Extension glob on base name", []string{"*.log"}, "logs/2026/app.log", true},
+		{"Doublestar prefix", []string{"**/build/**"}, "svc/build/out/app.bin", true},
+		{"Anchored path", []string{"docs/*.md"}, "docs/readme.md", true},
+		{"Anchored path does not match deeper", []string{"docs/*.md"}, "a/docs/readme.md", false},
+		{"No match", []string{"*.go", "vendor"}, "src/app.py", false},
+		{"No patterns", nil, "anything", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesAny(tt.patterns, tt.path); got != tt.want {
+				t.Errorf("matchesAny(%v, %q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if err := ValidatePatterns([]string{"[unclosed"}); err == nil {
+		t.Error("Expected invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,216 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type cacheEntry struct {
+	Path     string            `json:"path"`
+	Size     int64             `json:"size"`
+	ModTime  int64             `json:"mtime"`
+	Inode    uint64            `json:"inode"`
+	Hashes   map[string]string `json:"hashes"`
+	MimeType string            `json:"mime"`
+	Rules    string            `json:"rules,omitempty"`
+	Findings []Finding         `json:"findings,omitempty"`
+
+	//HasSimhash TELLS A FILE TOO SHORT FOR A SIMHASH FROM ONE CACHED WITHOUT IT
+	Simhash    string `json:"simhash,omitempty"`
+	HasSimhash bool   `json:"has_simhash,omitempty"`
+}
+
+// HashCache remembers the hash of every file from previous scans so unchanged
+// files (same path, size, mtime and inode) are not read again. It is stored as
+// an append-only JSON Lines log where later lines win.
+type HashCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	file    *os.File
+	writer  *bufio.Writer
+	hits    int
+	misses  int
+}
+
+func OpenHashCache(path string) (*HashCache, error) {
+	cache := &HashCache{
+		entries: make(map[string]cacheEntry),
+	}
+
+	//LOAD PREVIOUS ENTRIES, IGNORING A LINE TRUNCATED BY A CRASH
+	lines := 0
+	existing, err := os.Open(path)
+	if err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var entry cacheEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				continue
+			}
+			cache.entries[entry.Path] = entry
+			lines++
+		}
+		err = scanner.Err()
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading hash cache %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	//COMPACT THE LOG WHEN MOST OF IT IS SUPERSEDED ENTRIES
+	if lines > 2*len(cache.entries) {
+		if err := cache.rewrite(path); err != nil {
+			return nil, err
+		}
+	}
+
+	cache.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cache.writer = bufio.NewWriter(cache.file)
+
+	return cache, nil
+}
+
+// Lookup returns the cached result for a task if the file is unchanged since
+// it was last hashed, the cache holds a digest for every configured algorithm,
+// when secret detection is on, its findings came from the same rules and, when
+// near-duplicate detection is on, it holds the simhash.
+func (c *HashCache) Lookup(task FileTask, config ScanConfig) (ScanResult, bool) {
+	algorithms := config.HashAlgorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{DefaultHashAlgorithm}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[task.Path]
+	if !ok || entry.Size != task.Size || entry.ModTime != task.ModTime.UnixNano() || entry.Inode != task.Inode || entry.MimeType == "" {
+		c.misses++
+		return ScanResult{}, false
+	}
+
+	if config.Secrets != nil && entry.Rules != config.Secrets.Fingerprint {
+		c.misses++
+		return ScanResult{}, false
+	}
+
+	if config.Simhash && !entry.HasSimhash {
+		c.misses++
+		return ScanResult{}, false
+	}
+
+	hashes := make(map[string]string, len(algorithms))
+	for _, name := range algorithms {
+		digest, ok := entry.Hashes[name]
+		if !ok {
+			c.misses++
+			return ScanResult{}, false
+		}
+		hashes[name] = digest
+	}
+
+	c.hits++
+	return ScanResult{
+		Path:      task.Path,
+		Size:      task.Size,
+		ModTime:   task.ModTime,
+		Mode:      task.Mode,
+		Hash:      hashes[algorithms[0]],
+		Hashes:    hashes,
+		Extension: filepath.Ext(task.Path),
+		FileType:  filepath.Ext(task.Path),
+		MimeType:  entry.MimeType,
+		Findings:  entry.Findings,
+		Simhash:   entry.Simhash,
+	}, true
+}
+
+// Store records a freshly hashed file. Failed results are not cached.
+func (c *HashCache) Store(task FileTask, result ScanResult, config ScanConfig) {
+	if result.Error != "" || result.Hash == "" {
+		return
+	}
+
+	entry := cacheEntry{
+		Path:     task.Path,
+		Size:     task.Size,
+		ModTime:  task.ModTime.UnixNano(),
+		Inode:    task.Inode,
+		Hashes:   result.Hashes,
+		MimeType: result.MimeType,
+	}
+	if config.Secrets != nil {
+		entry.Rules = config.Secrets.Fingerprint
+		entry.Findings = result.Findings
+	}
+	if config.Simhash {
+		entry.Simhash = result.Simhash
+		entry.HasSimhash = true
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.Path] = entry
+	c.writer.Write(line)
+	c.writer.WriteByte('\n')
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *HashCache) Stats() (hits int, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *HashCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.writer.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// rewrite replaces the log with one line per live entry.
+func (c *HashCache) rewrite(path string) error {
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range c.entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHashCache_PersistsAcrossRuns tests that a reopened cache answers for unchanged files
+func TestHashCache_PersistsAcrossRuns(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "hashes.jsonl")
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	task := FileTask{Path: "/data/a.bin", Size: 10, ModTime: modTime, Inode: 42}
+
+	cache, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	cache.Store(task, ScanResult{
+		Path:     task.Path,
+		Size:     task.Size,
+		Hash:     "abc",
+		Hashes:   map[string]string{"sha256": "abc", "md5": "def"},
+		FileType: ".bin",
+		MimeType: "application/octet-stream",
+	}, ScanConfig{})
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	cache, err = OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	defer cache.Close()
+
+	result, ok := cache.Lookup(task, ScanConfig{HashAlgorithms: []string{"md5", "sha256"}})
+	if !ok {
+		t.Fatal("Expected cache hit for unchanged file")
+	}
+	if result.Hash != "def" || result.Hashes["sha256"] != "abc" || result.FileType != ".bin" || result.MimeType != "application/octet-stream" {
+		t.Errorf("Unexpected cached result: %+v", result)
+	}
+
+	// An algorithm the cache never computed must miss
+	if _, ok := cache.Lookup(task, ScanConfig{HashAlgorithms: []string{"sha1"}}); ok {
+		t.Error("Expected cache miss for missing algorithm")
+	}
+
+	// Any change to size, mtime or inode must miss
+	changed := []FileTask{
+		{Path: task.Path, Size: 11, ModTime: modTime, Inode: 42},
+		{Path: task.Path, Size: 10, ModTime: modTime.Add(time.Second), Inode: 42},
+		{Path: task.Path, Size: 10, ModTime: modTime, Inode: 43},
+	}
+	for _, c := range changed {
+		if _, ok := cache.Lookup(c, ScanConfig{}); ok {
+			t.Errorf("Expected cache miss for changed file %+v", c)
+		}
+	}
+
+	hits, misses := cache.Stats()
+	if hits != 1 || misses != 4 {
+		t.Errorf("Expected 1 hit and 4 misses, got %d and %d", hits, misses)
+	}
+}
+
+// TestHashCache_IgnoresTruncatedLine tests recovery from a log cut off mid-write
+func TestHashCache_IgnoresTruncatedLine(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "hashes.jsonl")
+	content := `{"path":"/a","size":1,"mtime":0,"inode":0,"hashes":{"sha256":"h1"},"mime":"text/plain"}` + "\n" + `{"path":"/b","si`
+	if err := os.WriteFile(cachePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	cache, err := OpenHashCache(cachePath)
+	if err != nil {
+		t.Fatalf("Expected truncated cache to open, got: %v", err)
+	}
+	defer cache.Close()
+
+	if _, ok := cache.Lookup(FileTask{Path: "/a", Size: 1, ModTime: time.Unix(0, 0)}, ScanConfig{}); !ok {
+		t.Error("Expected intact entry to survive")
+	}
+}
+
+// TestProcessTask_UsesCache tests that the worker skips reading cached files
+func TestProcessTask_UsesCache(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "data.txt")
+	os.WriteFile(testFile, []byte("original"), 0644)
+	info, _ := os.Stat(testFile)
+	task := FileTask{Path: testFile, Size: info.Size(), ModTime: info.ModTime(), Inode: fileInode(info)}
+
+	cache, err := OpenHashCache(filepath.Join(tempDir, "cache.jsonl"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer cache.Close()
+	config := ScanConfig{HashCache: cache}
+
+	first := processTask(task, config)
+
+	// Remove the file, a cache hit must not need to open it
+	os.Remove(testFile)
+	second := processTask(task, config)
+
+	if second.Error != "" {
+		t.Fatalf("Expected cached result, got error: %s", second.Error)
+	}
+	if second.Hash != first.Hash {
+		t.Errorf("Expected cached hash %s, got %s", first.Hash, second.Hash)
+	}
+}
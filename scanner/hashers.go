@@ -0,0 +1,83 @@
+package scanner
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// DefaultHashAlgorithm is used when a scan does not ask for any algorithm.
+const DefaultHashAlgorithm = "sha256"
+
+// hashAlgorithms maps an algorithm name to a constructor for its hasher.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"blake3": func() hash.Hash { return blake3.New(32, nil) },
+	"xxhash": func() hash.Hash { return xxhash.New() },
+}
+
+// RegisterHashAlgorithm makes another algorithm available to -hash.
+func RegisterHashAlgorithm(name string, newHash func() hash.Hash) {
+	hashAlgorithms[strings.ToLower(name)] = newHash
+}
+
+// HashAlgorithmNames returns the registered algorithm names in sorted order.
+func HashAlgorithmNames() []string {
+	names := make([]string, 0, len(hashAlgorithms))
+	for name := range hashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseHashAlgorithms parses a comma separated list such as "sha256,md5". The
+// first algorithm is the primary one used for duplicate detection.
+func ParseHashAlgorithms(spec string) ([]string, error) {
+	var algorithms []string
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := hashAlgorithms[name]; !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q (available: %s)", name, strings.Join(HashAlgorithmNames(), ", "))
+		}
+		seen[name] = true
+		algorithms = append(algorithms, name)
+	}
+
+	if len(algorithms) == 0 {
+		return []string{DefaultHashAlgorithm}, nil
+	}
+	return algorithms, nil
+}
+
+// newHashers builds one hasher per algorithm, falling back to the default
+// algorithm when none are configured.
+func newHashers(algorithms []string) (map[string]hash.Hash, string, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{DefaultHashAlgorithm}
+	}
+
+	hashers := make(map[string]hash.Hash, len(algorithms))
+	for _, name := range algorithms {
+		newHash, ok := hashAlgorithms[name]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown hash algorithm %q", name)
+		}
+		hashers[name] = newHash()
+	}
+	return hashers, algorithms[0], nil
+}
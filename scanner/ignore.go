@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	gitIgnoreFile  = ".gitignore"
+	scanIgnoreFile = ".scanignore"
+)
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// IgnoreMatcher applies .gitignore files found while walking a scan root, plus
+// a .scanignore at the root. Rules in deeper directories are applied after
+// those above them and the last matching rule wins, so "!pattern" can
+// re-include what a parent directory ignored.
+type IgnoreMatcher struct {
+	//RULES PER DIRECTORY, KEYED BY SLASH SEPARATED PATH RELATIVE TO THE ROOT
+	rules map[string][]ignoreRule
+}
+
+func NewIgnoreMatcher() *IgnoreMatcher {
+	return &IgnoreMatcher{rules: make(map[string][]ignoreRule)}
+}
+
+// LoadDir reads the ignore files of a directory as it is walked. relDir is
+// "." for the scan root, which also gets its .scanignore.
+func (m *IgnoreMatcher) LoadDir(relDir string, absDir string) error {
+	names := []string{gitIgnoreFile}
+	if relDir == "." {
+		//.scanignore IS READ LAST SO IT OVERRIDES THE ROOT .gitignore
+		names = append(names, scanIgnoreFile)
+	}
+
+	for _, name := range names {
+		rules, err := parseIgnoreFile(filepath.Join(absDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		m.rules[relDir] = append(m.rules[relDir], rules...)
+	}
+	return nil
+}
+
+// Ignored reports whether relPath, slash separated and relative to the scan
+// root, is ignored by the rules of its ancestor directories.
+func (m *IgnoreMatcher) Ignored(relPath string, isDir bool) bool {
+	ignored := false
+
+	//WALK FROM THE ROOT DOWN TO THE PARENT DIRECTORY
+	parts := strings.Split(relPath, "/")
+	base := "."
+	for i := 0; i < len(parts); i++ {
+		subPath := strings.Join(parts[i:], "/")
+		for _, rule := range m.rules[base] {
+			if rule.matches(subPath, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		base = path.Join(base, parts[i])
+	}
+
+	return ignored
+}
+
+// matches checks a rule against a path relative to the directory holding the
+// ignore file.
+func (r ignoreRule) matches(subPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	name := subPath
+	if !r.anchored {
+		name = path.Base(subPath)
+	}
+	matched, _ := doublestar.Match(r.pattern, name)
+	return matched
+}
+
+// parseIgnoreFile reads gitignore syntax: comments, "!" negation, a trailing
+// "/" for directories only, and a "/" anywhere else anchoring the pattern to
+// the directory of the ignore file.
+func parseIgnoreFile(filePath string) ([]ignoreRule, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		//TRAILING SPACES ARE IGNORED UNLESS ESCAPED
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" || !doublestar.ValidatePattern(line) {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// TestDiscoverFiles_IgnoreFiles tests hierarchical .gitignore and root .scanignore handling
+func TestDiscoverFiles_IgnoreFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		".gitignore":            "# build output\nbuild/\n*.log\n!keep.log\n",
+		".scanignore":           "vendor\n",
+		"main.go":               "package main",
+		"debug.log":             "noise",
+		"keep.log":              "wanted",
+		"build/app.bin":         "binary",
+		"vendor/lib.go":         "vendored",
+		"sub/.gitignore":        "!*.log\n/local.txt\n",
+		"sub/trace.log":         "re-included by sub/.gitignore",
+		"sub/local.txt":         "anchored to sub",
+		"sub/deeper/local.txt":  "anchored pattern does not reach here",
+		"sub/deeper/build/x.go": "build/ applies at any depth",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(tempDir, name)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		os.WriteFile(fullPath, []byte(content), 0644)
+	}
+
+	tasksChannel := make(chan FileTask, 20)
+	ctx := context.Background()
+	metrics := &ScanMetrics{}
+	metricsMutex := &sync.RWMutex{}
+
+	config := ScanConfig{
+		Directories:    []string{tempDir},
+		MaxFileSize:    1024 * 1024,
+		UseIgnoreFiles: true,
+	}
+
+	go DiscoverFiles(ctx, config, tasksChannel, metrics, metricsMutex)
+
+	var found []string
+	for task := range tasksChannel {
+		relPath, _ := filepath.Rel(tempDir, task.Path)
+		found = append(found, filepath.ToSlash(relPath))
+	}
+	sort.Strings(found)
+
+	expected := []string{
+		".gitignore",
+		".scanignore",
+		"keep.log",
+		"main.go",
+		"sub/.gitignore",
+		"sub/deeper/local.txt",
+		"sub/trace.log",
+	}
+
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, found)
+			break
+		}
+	}
+}
+
+// TestParseIgnoreFile tests gitignore syntax parsing
+func TestParseIgnoreFile(t *testing.T) {
+	ignorePath := filepath.Join(t.TempDir(), ".gitignore")
+	content := "# comment\n\n\\#literal\n!negated\ndir/\n/rooted\nnested/path\n"
+	os.WriteFile(ignorePath, []byte(content), 0644)
+
+	rules, err := parseIgnoreFile(ignorePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []ignoreRule{
+		{pattern: "#literal"},
+		{pattern: "negated", negate: true},
+		{pattern: "dir", dirOnly: true},
+		{pattern: "rooted", anchored: true},
+		{pattern: "nested/path", anchored: true},
+	}
+
+	if len(rules) != len(expected) {
+		t.Fatalf("Expected %d rules, got %d: %+v", len(expected), len(rules), rules)
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Errorf("Rule %d: expected %+v, got %+v", i, expected[i], rules[i])
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package scanner
+
+import "os"
+
+// fileInode returns 0 on platforms without inode numbers, so the hash cache
+// falls back to path, size and mtime.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
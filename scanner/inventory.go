@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// inventoryHeader is the first row of a CSV inventory.
+var inventoryHeader = []string{"path", "size", "mtime", "mode", "hash", "type", "error"}
+
+// InventoryRecord is one file of the per-file inventory.
+type InventoryRecord struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Mode    string    `json:"mode"`
+	Hash    string    `json:"hash,omitempty"`
+	Type    string    `json:"type"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func newInventoryRecord(result ScanResult) InventoryRecord {
+	return InventoryRecord{
+		Path:    result.Path,
+		Size:    result.Size,
+		ModTime: result.ModTime,
+		Mode:    result.Mode.String(),
+		Hash:    result.Hash,
+		Type:    result.FileType,
+		Error:   result.Error,
+	}
+}
+
+// LoadInventory reads a JSON Lines or CSV inventory written by -output,
+// telling them apart by their content and calling fn for every record so
+// large inventories are never fully loaded.
+func LoadInventory(path string, fn func(InventoryRecord)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	//JSON LINES START WITH AN OBJECT, WHATEVER THE FILE IS NAMED
+	reader := bufio.NewReader(file)
+	if first, err := firstByte(reader); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	} else if first != '{' {
+		return readCSVInventory(path, reader, fn)
+	}
+
+	decoder := json.NewDecoder(reader)
+	for line := 1; ; line++ {
+		var record InventoryRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: record %d: %w", path, line, err)
+		}
+		fn(record)
+	}
+}
+
+func readCSVInventory(path string, file io.Reader, fn func(InventoryRecord)) error {
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = len(inventoryHeader)
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if line == 1 && row[0] == inventoryHeader[0] {
+			continue
+		}
+
+		record := InventoryRecord{Path: row[0], Mode: row[3], Hash: row[4], Type: row[5], Error: row[6]}
+		if record.Size, err = strconv.ParseInt(row[1], 10, 64); err != nil {
+			return fmt.Errorf("%s:%d: invalid size %q", path, line, row[1])
+		}
+		if row[2] != "" {
+			if record.ModTime, err = time.Parse(time.RFC3339Nano, row[2]); err != nil {
+				return fmt.Errorf("%s:%d: invalid mtime %q", path, line, row[2])
+			}
+		}
+		fn(record)
+	}
+}
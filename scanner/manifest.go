@@ -0,0 +1,256 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestEntry is the expected digest of one path.
+type ManifestEntry struct {
+	Algorithm string
+	Digest    string
+}
+
+// Manifest maps expected paths to their digests.
+type Manifest map[string]ManifestEntry
+
+// LoadManifest reads a baseline in sha256sum format ("digest  path", also
+// md5sum/sha1sum) or as a JSON object of path to digest. The algorithm of each
+// digest is told apart by its length. Relative paths, as sha256sum writes
+// them, are matched against the scanned roots. A saved Scan_Results.json is refused
+// since it only lists the files of duplicate groups.
+func LoadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONManifest(path, trimmed)
+	}
+	return parseChecksumManifest(path, data)
+}
+
+func parseJSONManifest(path string, data []byte) (Manifest, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	if _, ok := raw["Duplicates"]; ok {
+		return nil, fmt.Errorf("%s is a saved scan result, which only records duplicate groups; create a baseline with -write-manifest", path)
+	}
+
+	manifest := make(Manifest, len(raw))
+	for filePath, value := range raw {
+		var digest string
+		if err := json.Unmarshal(value, &digest); err != nil {
+			return nil, fmt.Errorf("%s: digest for %s must be a string", path, filePath)
+		}
+		entry, err := newManifestEntry(digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, filePath, err)
+		}
+		manifest[filepath.Clean(filePath)] = entry
+	}
+	return manifest, nil
+}
+
+func parseChecksumManifest(path string, data []byte) (Manifest, error) {
+	manifest := make(Manifest)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		//"digest  path" FOR TEXT MODE, "digest *path" FOR BINARY MODE
+		separator := strings.IndexAny(line, " \t")
+		if separator < 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"digest  path\"", path, lineNumber)
+		}
+		digest := line[:separator]
+		filePath := strings.TrimLeft(line[separator:], " \t")
+		filePath = strings.TrimPrefix(filePath, "*")
+		if filePath == "" {
+			return nil, fmt.Errorf("%s:%d: missing path", path, lineNumber)
+		}
+
+		entry, err := newManifestEntry(digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		manifest[filepath.Clean(filePath)] = entry
+	}
+	return manifest, scanner.Err()
+}
+
+func newManifestEntry(digest string) (ManifestEntry, error) {
+	digest = strings.ToLower(strings.TrimSpace(digest))
+	algorithm, ok := digestAlgorithms[len(digest)]
+	if _, err := hex.DecodeString(digest); err != nil || !ok {
+		return ManifestEntry{}, fmt.Errorf("%q is not an MD5, SHA-1 or SHA-256 digest", digest)
+	}
+	return ManifestEntry{Algorithm: algorithm, Digest: digest}, nil
+}
+
+// Algorithms returns the hash algorithms needed to check the manifest.
+func (m Manifest) Algorithms() []string {
+	seen := make(map[string]bool)
+	for _, entry := range m {
+		seen[entry.Algorithm] = true
+	}
+
+	algorithms := make([]string, 0, len(seen))
+	for algorithm := range seen {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// BaselineReport lists how a scan differs from its baseline manifest.
+type BaselineReport struct {
+	Expected   int      `json:"expected"`
+	Matched    int      `json:"matched"`
+	New        []string `json:"new"`
+	Missing    []string `json:"missing"`
+	Changed    []string `json:"changed"`
+	Unreadable []string `json:"unreadable,omitempty"`
+}
+
+// Clean reports whether the scan matched the baseline exactly.
+func (r BaselineReport) Clean() bool {
+	return len(r.New) == 0 && len(r.Missing) == 0 && len(r.Changed) == 0 && len(r.Unreadable) == 0
+}
+
+// Baseline checks scan results against a manifest as the collector receives
+// them.
+type Baseline struct {
+	manifest Manifest
+	seen     map[string]bool
+	report   BaselineReport
+
+	//SET BY Scanner, RELATIVE MANIFEST PATHS ARE RELATIVE TO ONE OF THESE
+	roots []string
+}
+
+func NewBaseline(manifest Manifest) *Baseline {
+	return &Baseline{
+		manifest: manifest,
+		seen:     make(map[string]bool, len(manifest)),
+		report:   BaselineReport{Expected: len(manifest)},
+	}
+}
+
+// Observe compares one scan result with the manifest.
+func (b *Baseline) Observe(result ScanResult) {
+	path := filepath.Clean(result.Path)
+	key, expected := b.lookup(path)
+	if !expected {
+		b.report.New = append(b.report.New, path)
+		return
+	}
+	entry := b.manifest[key]
+	b.seen[key] = true
+
+	if result.Error != "" {
+		b.report.Unreadable = append(b.report.Unreadable, path)
+		return
+	}
+	if result.Hashes[entry.Algorithm] != entry.Digest {
+		b.report.Changed = append(b.report.Changed, path)
+		return
+	}
+	b.report.Matched++
+}
+
+// lookup returns the manifest key of a scanned path: the path itself or, for
+// a manifest of relative paths, the path relative to the root it was found
+// under.
+func (b *Baseline) lookup(path string) (string, bool) {
+	if _, ok := b.manifest[path]; ok {
+		return path, true
+	}
+	for _, root := range b.roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if _, ok := b.manifest[rel]; ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// Report returns the differences once every result has been observed.
+func (b *Baseline) Report() BaselineReport {
+	report := b.report
+	report.Missing = nil
+	for path := range b.manifest {
+		if !b.seen[path] {
+			report.Missing = append(report.Missing, path)
+		}
+	}
+
+	report.New = sortedCopy(report.New)
+	report.Changed = sortedCopy(report.Changed)
+	report.Unreadable = sortedCopy(report.Unreadable)
+	sort.Strings(report.Missing)
+	return report
+}
+
+// CanWriteManifest reports whether WriteManifest can use algorithm as the
+// primary hash. Manifests only hold digests LoadManifest can recognise.
+func CanWriteManifest(algorithm string) bool {
+	return digestAlgorithmNames[algorithm]
+}
+
+// WriteManifest saves every hashed file of a scan in sha256sum format using
+// the primary hash algorithm, ready to be used as a later baseline.
+func WriteManifest(metrics *ScanMetrics, fileName string) error {
+	type line struct{ path, digest string }
+	var lines []line
+	for key, paths := range metrics.Duplicates {
+		//GROUPS SPLIT BY -verify ARE KEYED "hash#2", THE FILES STILL HAVE THE PLAIN DIGEST
+		digest, _, _ := strings.Cut(key, splitGroupSeparator)
+		for _, path := range paths {
+			lines = append(lines, line{path, digest})
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].path < lines[j].path })
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, l := range lines {
+		fmt.Fprintf(writer, "%s  %s\n", l.digest, l.path)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func sortedCopy(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	worldSHA256 = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+// TestLoadManifest_Formats tests sha256sum and JSON baselines
+func TestLoadManifest_Formats(t *testing.T) {
+	tempDir := t.TempDir()
+
+	sumFile := filepath.Join(tempDir, "baseline.sha256")
+	os.WriteFile(sumFile, []byte(
+		"# release 1.0\n"+
+			helloSHA256+"  /srv/app/hello.txt\n"+
+			strings.ToUpper(worldSHA256)+" */srv/app/world.bin\n",
+	), 0644)
+
+	manifest, err := LoadManifest(sumFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(manifest))
+	}
+	if entry := manifest["/srv/app/world.bin"]; entry.Algorithm != "sha256" || entry.Digest != worldSHA256 {
+		t.Errorf("Expected lowercased sha256 entry for binary mode line, got %+v", entry)
+	}
+
+	jsonFile := filepath.Join(tempDir, "baseline.json")
+	os.WriteFile(jsonFile, []byte(`{"/srv/app/hello.txt": "5d41402abc4b2a76b9719d911017c592"}`), 0644)
+
+	manifest, err = LoadManifest(jsonFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if algorithms := manifest.Algorithms(); len(algorithms) != 1 || algorithms[0] != "md5" {
+		t.Errorf("Expected [md5], got %v", algorithms)
+	}
+
+	savedResults := filepath.Join(tempDir, "Scan_Results.json")
+	os.WriteFile(savedResults, []byte(`{"TotalFiles": 2, "Duplicates": {}}`), 0644)
+	if _, err := LoadManifest(savedResults); err == nil || !strings.Contains(err.Error(), "-write-manifest") {
+		t.Errorf("Expected saved scan results to be rejected, got %v", err)
+	}
+
+	bad := filepath.Join(tempDir, "bad.sha256")
+	os.WriteFile(bad, []byte("nothex  /srv/app/hello.txt\n"), 0644)
+	if _, err := LoadManifest(bad); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Expected error with line number, got %v", err)
+	}
+}
+
+// TestBaseline_Report tests new, missing, changed and unreadable files
+func TestBaseline_Report(t *testing.T) {
+	baseline := NewBaseline(Manifest{
+		"/srv/app/hello.txt":  {Algorithm: "sha256", Digest: helloSHA256},
+		"/srv/app/world.bin":  {Algorithm: "sha256", Digest: worldSHA256},
+		"/srv/app/config.ini": {Algorithm: "sha256", Digest: helloSHA256},
+		"/srv/app/secret.key": {Algorithm: "sha256", Digest: helloSHA256},
+	})
+
+	baseline.Observe(ScanResult{Path: "/srv/app/hello.txt", Hashes: map[string]string{"sha256": helloSHA256}})
+	baseline.Observe(ScanResult{Path: "/srv/app/world.bin", Hashes: map[string]string{"sha256": helloSHA256}})
+	baseline.Observe(ScanResult{Path: "/srv/app/secret.key", Error: "permission denied"})
+	baseline.Observe(ScanResult{Path: "/srv/app/extra.txt", Hashes: map[string]string{"sha256": worldSHA256}})
+
+	report := baseline.Report()
+	if report.Expected != 4 || report.Matched != 1 {
+		t.Errorf("Expected 1 of 4 matched, got %d of %d", report.Matched, report.Expected)
+	}
+	if len(report.New) != 1 || report.New[0] != "/srv/app/extra.txt" {
+		t.Errorf("Expected extra.txt to be new, got %v", report.New)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "/srv/app/config.ini" {
+		t.Errorf("Expected config.ini to be missing, got %v", report.Missing)
+	}
+	if len(report.Changed) != 1 || report.Changed[0] != "/srv/app/world.bin" {
+		t.Errorf("Expected world.bin to be changed, got %v", report.Changed)
+	}
+	if len(report.Unreadable) != 1 || report.Unreadable[0] != "/srv/app/secret.key" {
+		t.Errorf("Expected secret.key to be unreadable, got %v", report.Unreadable)
+	}
+	if report.Clean() {
+		t.Error("Expected report with differences not to be clean")
+	}
+}
+
+// TestWriteManifest_RoundTrip tests that a written manifest loads back
+func TestWriteManifest_RoundTrip(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "baseline.sha256")
+	metrics := &ScanMetrics{Duplicates: map[string][]string{
+		helloSHA256: {"/srv/b.txt", "/srv/a.txt"},
+		worldSHA256: {"/srv/c.bin"},
+	}}
+
+	if err := WriteManifest(metrics, fileName); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(fileName)
+	if !strings.HasPrefix(string(data), helloSHA256+"  /srv/a.txt\n") {
+		t.Errorf("Expected manifest sorted by path, got:\n%s", data)
+	}
+
+	manifest, err := LoadManifest(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	baseline := NewBaseline(manifest)
+	for digest, paths := range metrics.Duplicates {
+		for _, path := range paths {
+			baseline.Observe(ScanResult{Path: path, Hashes: map[string]string{"sha256": digest}})
+		}
+	}
+	if report := baseline.Report(); !report.Clean() || report.Matched != 3 {
+		t.Errorf("Expected clean report with 3 matches, got %+v", report)
+	}
+}
+
+// TestWriteManifest_SplitGroups tests that groups split by -verify are written
+// with their plain digest
+func TestWriteManifest_SplitGroups(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "baseline.sha256")
+	metrics := &ScanMetrics{Duplicates: map[string][]string{
+		helloSHA256:                             {"/srv/a.txt"},
+		helloSHA256 + splitGroupSeparator + "2": {"/srv/b.txt"},
+	}}
+	if err := WriteManifest(metrics, fileName); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	manifest, err := LoadManifest(fileName)
+	if err != nil {
+		t.Fatalf("Expected the manifest to load, got %v", err)
+	}
+	if manifest["/srv/b.txt"].Digest != helloSHA256 {
+		t.Errorf("Expected the plain digest for b.txt, got %+v", manifest["/srv/b.txt"])
+	}
+}
+
+// TestScanner_RunRelativeManifest tests that a sha256sum manifest of relative
+// paths, written from inside the tree, matches a scan of its absolute root
+func TestScanner_RunRelativeManifest(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dist", "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"dist/app.bin": "hello", "dist/lib/b.so": "world"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//AS WRITTEN BY "cd root && sha256sum ./dist/app.bin && sha256sum -b dist/lib/b.so"
+	manifestPath := filepath.Join(t.TempDir(), "release.sha256")
+	content := helloSHA256 + "  ./dist/app.bin\n" + worldSHA256 + " *dist/lib/b.so\n"
+	if err := os.WriteFile(manifestPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	s, err := New(ScanConfig{Directories: []string{root}, Baseline: NewBaseline(manifest)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected scan to complete, got %v", err)
+	}
+	if report := metrics.Baseline; !report.Clean() || report.Matched != 2 {
+		t.Errorf("Expected both relative paths to match, got %+v", report)
+	}
+}
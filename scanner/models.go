@@ -0,0 +1,160 @@
+package scanner
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+type FileTask struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Mode    os.FileMode
+	Inode   uint64
+
+	//SET WHEN THE PREFILTER PROVED THE FILE HAS NO DUPLICATE
+	SkipHash bool
+}
+
+type ScanResult struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Mode    os.FileMode
+
+	//HASH IS THE PRIMARY DIGEST USED FOR DUPLICATES, HASHES HOLDS EVERY ALGORITHM
+	Hash   string
+	Hashes map[string]string
+
+	//FILETYPE IS THE KEY TypeCount AGGREGATES BY, EITHER THE EXTENSION OR THE MIME TYPE
+	FileType  string
+	Extension string
+	MimeType  string
+	Error     string
+	Findings  []Finding
+
+	//ON-DISK ARCHIVE AN ENTRY WAS READ FROM, EMPTY FOR REGULAR FILES
+	Archive string
+
+	//HEX SIMHASH FOR NEAR-DUPLICATE DETECTION, EMPTY WHEN OFF OR THE FILE IS TOO SHORT
+	Simhash string
+}
+
+type ScanMetrics struct {
+	TotalFiles           int
+	TotalBytes           int64
+	FilesScanned         int
+	FilesPending         int
+	HashAlgorithms       []string
+	Duplicates           map[string][]string
+	Hashes               map[string]map[string]string
+	Sizes                map[string]int64
+	DuplicateFilesCount  int
+	ReclaimableBytes     int64
+	Reclaimable          map[string]int64
+	TopWaste             []DuplicateGroupWaste
+	TopWasteDirectories  []DirectoryWaste
+	DuplicateDirectories []DirectoryGroup
+	SubsetDirectories    []DirectorySubset
+	NearDuplicates       []NearDuplicateGroup
+	Verification         map[string]string
+	TypeCount            map[string]int
+	Errors               []FileError
+	Findings             []Finding
+	DenylistMatches      []DenylistMatch
+	Baseline             *BaselineReport
+	StartTime            time.Time
+	EndTime              time.Time
+
+	//EVERY RECORD BY PATH, SET BY LoadScanResults FOR AN INVENTORY, WHICH LISTS EVERY FILE RATHER THAN ONLY DUPLICATES
+	Inventory map[string]InventoryRecord `json:"-"`
+}
+
+type ScanConfig struct {
+	Directories []string
+	WorkerCount int
+	MaxFileSize int64
+	Include     []string
+	Exclude     []string
+	Prefilter   bool
+
+	//PATTERNS OF SINGLE DIRECTORIES, KEYED BY THE DIRECTORY AS GIVEN IN Directories
+	RootRules map[string]RootRules
+
+	//AGGREGATE TypeCount BY "extension" OR BY SNIFFED "mime" TYPE
+	TypeCountBy string
+
+	//HOW MANY LEVELS OF NESTED ARCHIVES TO EXPAND, 0 DISABLES EXPANSION
+	ArchiveDepth int
+
+	//LARGEST NESTED ARCHIVE READ INTO MEMORY TO EXPAND IT, 0 MEANS DefaultMaxNestedArchiveSize
+	MaxNestedArchiveSize int64
+
+	//HONOR .gitignore FILES AND A ROOT .scanignore DURING DISCOVERY
+	UseIgnoreFiles bool
+
+	//FIRST ALGORITHM IS THE PRIMARY ONE USED FOR DUPLICATE DETECTION
+	HashAlgorithms []string
+
+	//COMPUTE A SIMHASH OF EVERY FILE IN THE HASHING READ PASS
+	Simhash bool
+
+	//SECRET DETECTION RULES RUN IN THE HASHING READ PASS, NIL DISABLES THEM
+	Secrets *SecretRuleSet
+
+	//KNOWN-BAD DIGESTS FLAGGED BY THE COLLECTOR, NIL DISABLES MATCHING
+	Denylist *Denylist
+
+	//BASELINE MANIFEST THE COLLECTOR CHECKS RESULTS AGAINST, NIL DISABLES IT
+	Baseline *Baseline
+
+	//-output DESTINATIONS THE COLLECTOR STREAMS EVERY RESULT TO
+	Sinks []ResultSink
+
+	//CLUSTERS SIMHASHES INTO NEAR-DUPLICATE GROUPS, NIL DISABLES IT
+	NearDuplicates *NearDuplicateIndex
+
+	//COLLECTED PATHS OF A RESUMABLE SCAN, NIL DISABLES CHECKPOINTS
+	Checkpoint *Checkpoint
+
+	HashCache *HashCache
+
+	//SET BY Scanner TO RUN ITS CALLBACKS FOR EVERY COLLECTED RESULT
+	onResult func(ScanResult)
+
+	//SET BY WithLogger AND AgentConfig.Logger, NIL DISCARDS MESSAGES
+	logger *log.Logger
+}
+
+// logf reports a message of the scan through its logger, if it has one.
+func (config ScanConfig) logf(format string, args ...any) {
+	if config.logger != nil {
+		config.logger.Printf(format, args...)
+	}
+}
+
+// RootRules are the include and exclude patterns of one scanned directory. A
+// non-empty Include replaces the Include of the ScanConfig for that directory,
+// Exclude is added to its Exclude.
+type RootRules struct {
+	Include []string
+	Exclude []string
+}
+
+type AgentConfig struct {
+	CoordinatorURL string
+	AgentID        string
+	Roots          []string
+	BatchSize      int
+	RetryInterval  time.Duration
+
+	//RECEIVES THE AGENT'S MESSAGES, NIL DISCARDS THEM
+	Logger *log.Logger
+}
+
+type FileError struct {
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
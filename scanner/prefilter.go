@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// partialHashSize is how many bytes are read from each end of a file for the
+// partial hash stage.
+const partialHashSize = 4 * 1024
+
+// PrefilterTasks marks the tasks that cannot have a duplicate so workers skip
+// hashing them. Files with a unique size are marked straight away. Files that
+// share a size are compared by a partial hash of their first and last few KB,
+// and only those whose partial hash still collides are left for a full hash.
+// Cancelling ctx stops the partial hashing and returns the context's error.
+func PrefilterTasks(ctx context.Context, tasks []FileTask, workerCount int) ([]FileTask, error) {
+	if workerCount <= 0 {
+		workerCount = 1
+	}
+
+	//STAGE 1: GROUP BY SIZE
+	sizeCount := make(map[int64]int)
+	for _, task := range tasks {
+		sizeCount[task.Size]++
+	}
+
+	var candidates []int
+	for i, task := range tasks {
+		switch {
+		case sizeCount[task.Size] == 1:
+			tasks[i].SkipHash = true
+		case task.Size > 2*partialHashSize:
+			//SMALLER FILES WOULD BE READ IN FULL BY THE PARTIAL HASH ANYWAY
+			candidates = append(candidates, i)
+		}
+	}
+
+	//STAGE 2: PARTIAL HASH OF SAME-SIZE CANDIDATES
+	partialHashes := make([]string, len(tasks))
+	indexes := make(chan int)
+
+	var workerWaitGroup sync.WaitGroup
+	workerWaitGroup.Add(workerCount)
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			defer workerWaitGroup.Done()
+			for index := range indexes {
+				hash, err := partialHash(tasks[index])
+				if err != nil {
+					//LEAVE IT FOR THE FULL HASH, WHICH REPORTS THE ERROR
+					continue
+				}
+				partialHashes[index] = hash
+			}
+		}()
+	}
+feed:
+	for _, index := range candidates {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	workerWaitGroup.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	partialCount := make(map[string]int)
+	for _, index := range candidates {
+		if partialHashes[index] != "" {
+			partialCount[fmt.Sprintf("%d:%s", tasks[index].Size, partialHashes[index])]++
+		}
+	}
+	for _, index := range candidates {
+		if partialHashes[index] == "" {
+			continue
+		}
+		if partialCount[fmt.Sprintf("%d:%s", tasks[index].Size, partialHashes[index])] == 1 {
+			tasks[index].SkipHash = true
+		}
+	}
+
+	//STAGE 3 IS THE FULL HASH DONE BY THE WORKERS
+	return tasks, nil
+}
+
+// partialHash hashes the first and last partialHashSize bytes of a file.
+func partialHash(task FileTask) (string, error) {
+	file, err := os.Open(task.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.CopyN(hasher, file, partialHashSize); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(task.Size-partialHashSize, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(hasher, file, partialHashSize); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
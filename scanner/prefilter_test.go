@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPrefilterTasks_SkipsFilesThatCannotCollide tests the size and partial hash stages
+func TestPrefilterTasks_SkipsFilesThatCannotCollide(t *testing.T) {
+	tempDir := t.TempDir()
+
+	large := bytes.Repeat([]byte("x"), 3*partialHashSize)
+	differentHead := append([]byte("y"), large[1:]...)
+
+	files := map[string][]byte{
+		"unique.txt":     []byte("only file of this size"),
+		"small1.txt":     []byte("same"),
+		"small2.txt":     []byte("size"),
+		"large1.bin":     large,
+		"large2.bin":     large,
+		"large-diff.bin": differentHead,
+	}
+
+	var tasks []FileTask
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		tasks = append(tasks, FileTask{Path: path, Size: int64(len(content))})
+	}
+
+	tasks, err := PrefilterTasks(context.Background(), tasks, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectSkip := map[string]bool{
+		"unique.txt":     true,
+		"small1.txt":     false, // too small for a partial hash to help
+		"small2.txt":     false,
+		"large1.bin":     false,
+		"large2.bin":     false,
+		"large-diff.bin": true, // same size, different partial hash
+	}
+
+	for _, task := range tasks {
+		name := filepath.Base(task.Path)
+		if task.SkipHash != expectSkip[name] {
+			t.Errorf("%s: expected SkipHash=%v, got %v", name, expectSkip[name], task.SkipHash)
+		}
+	}
+}
+
+// TestProcessFile_SkipHash tests that prefiltered files are classified but not hashed
+func TestProcessFile_SkipHash(t *testing.T) {
+	task := FileTask{Path: "/path/that/does/not/exist.txt", Size: 100, SkipHash: true}
+
+	result := ProcessFiles(task, ScanConfig{})
+
+	if result.Error != "" {
+		t.Errorf("Expected skipped file not to be opened, got error: %s", result.Error)
+	}
+	if result.Hash != "" {
+		t.Errorf("Expected empty hash, got %s", result.Hash)
+	}
+	if result.FileType != ".txt" {
+		t.Errorf("Expected file type .txt, got %s", result.FileType)
+	}
+}
+
+// TestPrefilterTasks_Cancelled tests that a cancelled prefilter stops reading
+// candidates and returns the context's error
+func TestPrefilterTasks_Cancelled(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 3*partialHashSize)
+	var tasks []FileTask
+	for _, name := range []string{"a.bin", "b.bin"} {
+		path := filepath.Join(t.TempDir(), name)
+		os.WriteFile(path, large, 0644)
+		tasks = append(tasks, FileTask{Path: path, Size: int64(len(large))})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := PrefilterTasks(ctx, tasks, 1); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
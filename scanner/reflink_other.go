@@ -0,0 +1,13 @@
+//go:build !linux
+
+package scanner
+
+import (
+	"errors"
+	"os"
+)
+
+// reflinkFile is only implemented on Linux.
+func reflinkFile(src, dst *os.File) error {
+	return errors.New("reflinks are not supported on this platform")
+}
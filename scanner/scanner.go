@@ -0,0 +1,520 @@
+// Package scanner walks directory trees, hashes every file with a pool of
+// workers and aggregates the results into duplicate groups, type counts,
+// secret findings and the other reports of a scan.
+//
+// A Scanner is built from a ScanConfig and options, and runs once:
+//
+//	s, err := scanner.New(scanner.ScanConfig{Directories: []string{"/srv"}},
+//		scanner.WithVerify(),
+//		scanner.OnFinding(func(f scanner.Finding) { log.Println(f.Path, f.RuleID) }))
+//	if err != nil {
+//		return err
+//	}
+//	metrics, err := s.Run(ctx)
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the counters of a running scan.
+type Progress struct {
+	FilesScanned int
+	FilesPending int
+	TotalBytes   int64
+	Errors       int
+}
+
+// Option configures a Scanner beyond its ScanConfig.
+type Option func(*Scanner) error
+
+// Scanner runs one scan of a ScanConfig. Its metrics can be read while it
+// runs through View and Progress.
+type Scanner struct {
+	config ScanConfig
+
+	coordinatorAddr     string
+	checkpointPath      string
+	checkpointInterval  time.Duration
+	resume              bool
+	outputs             []string
+	verify              bool
+	directoryDuplicates bool
+
+	onResult         func(ScanResult)
+	onError          func(FileError)
+	onFinding        func(Finding)
+	onProgress       func(Progress)
+	progressInterval time.Duration
+
+	metrics      *ScanMetrics
+	metricsMutex sync.RWMutex
+}
+
+// New checks config, fills in defaults for the fields left empty and applies
+// the options.
+func New(config ScanConfig, options ...Option) (*Scanner, error) {
+	if len(config.Directories) == 0 {
+		config.Directories = []string{"."}
+	}
+	if config.WorkerCount <= 0 {
+		config.WorkerCount = runtime.NumCPU()
+	}
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = math.MaxInt64
+	}
+	if config.MaxNestedArchiveSize <= 0 {
+		config.MaxNestedArchiveSize = DefaultMaxNestedArchiveSize
+	}
+	if len(config.HashAlgorithms) == 0 {
+		config.HashAlgorithms = []string{DefaultHashAlgorithm}
+	}
+	if config.TypeCountBy == "" {
+		config.TypeCountBy = TypeByExtension
+	}
+
+	if config.TypeCountBy != TypeByExtension && config.TypeCountBy != TypeByMime {
+		return nil, fmt.Errorf("type count must be by %q or %q, not %q", TypeByExtension, TypeByMime, config.TypeCountBy)
+	}
+	for _, name := range config.HashAlgorithms {
+		if _, ok := hashAlgorithms[name]; !ok {
+			return nil, fmt.Errorf("unknown hash algorithm %q", name)
+		}
+	}
+	for _, patterns := range [][]string{config.Include, config.Exclude} {
+		if err := ValidatePatterns(patterns); err != nil {
+			return nil, err
+		}
+	}
+	for dir, rules := range config.RootRules {
+		for _, patterns := range [][]string{rules.Include, rules.Exclude} {
+			if err := ValidatePatterns(patterns); err != nil {
+				return nil, fmt.Errorf("%s: %w", dir, err)
+			}
+		}
+	}
+
+	s := &Scanner{
+		config:             config,
+		checkpointInterval: DefaultCheckpointInterval,
+	}
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.resume && s.checkpointPath == "" {
+		return nil, errors.New("resuming needs a checkpoint to resume from")
+	}
+	if s.resume && s.config.NearDuplicates != nil {
+		return nil, errors.New("near-duplicate detection cannot be resumed, simhashes are not checkpointed")
+	}
+
+	s.metrics = &ScanMetrics{
+		HashAlgorithms: s.config.HashAlgorithms,
+		Duplicates:     make(map[string][]string),
+		TypeCount:      make(map[string]int),
+		Errors:         make([]FileError, 0),
+	}
+	return s, nil
+}
+
+// WithCoordinator hands the files out to remote agents connecting on addr
+// instead of hashing them with local workers.
+func WithCoordinator(addr string) Option {
+	return func(s *Scanner) error {
+		if addr == "" {
+			return errors.New("coordinator needs an address to listen on")
+		}
+		s.coordinatorAddr = addr
+		return nil
+	}
+}
+
+// WithCheckpoint saves the progress of the scan to path every interval and
+// when it is cancelled. The checkpoint is removed once the scan completes.
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(s *Scanner) error {
+		if interval <= 0 {
+			return errors.New("checkpoint interval must be positive")
+		}
+		s.checkpointPath = path
+		s.checkpointInterval = interval
+		return nil
+	}
+}
+
+// WithResume continues the scan saved in the checkpoint, skipping the files it
+// already collected. Without a saved checkpoint the scan starts afresh.
+func WithResume() Option {
+	return func(s *Scanner) error {
+		s.resume = true
+		return nil
+	}
+}
+
+// WithOutputs streams every result to the [jsonl|csv|sqlite:]PATH specs. The
+// Scanner opens them when it runs and closes them when it is done. SQLite
+// outputs are rejected by builds without cgo.
+func WithOutputs(specs ...string) Option {
+	return func(s *Scanner) error {
+		for _, spec := range specs {
+			if format, _ := parseOutputSpec(spec); format == OutputSQLite && !sqliteAvailable {
+				return fmt.Errorf("output %s: %w", spec, errSQLiteUnavailable)
+			}
+		}
+		s.outputs = append(s.outputs, specs...)
+		return nil
+	}
+}
+
+// WithVerify compares the files of every duplicate group byte for byte once
+// the scan completes, splitting groups that differ.
+func WithVerify() Option {
+	return func(s *Scanner) error {
+		s.verify = true
+		return nil
+	}
+}
+
+// WithDirectoryDuplicates reports identical directories and directories
+// contained in others once the scan completes.
+func WithDirectoryDuplicates() Option {
+	return func(s *Scanner) error {
+		s.directoryDuplicates = true
+		return nil
+	}
+}
+
+// WithSimilarity groups files that are at least threshold similar without
+// being identical into NearDuplicates.
+func WithSimilarity(threshold float64) Option {
+	return func(s *Scanner) error {
+		index, err := NewNearDuplicateIndex(threshold)
+		if err != nil {
+			return err
+		}
+		s.config.Simhash = true
+		s.config.NearDuplicates = index
+		return nil
+	}
+}
+
+// WithLogger sends the messages of the scan, such as why the prefilter was
+// disabled or which files could not be walked, to logger. Without it a
+// Scanner prints nothing.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Scanner) error {
+		s.config.logger = logger
+		return nil
+	}
+}
+
+// OnResult calls fn with every collected result. Callbacks run on the
+// collector goroutine, so a slow callback slows the scan down.
+func OnResult(fn func(ScanResult)) Option {
+	return func(s *Scanner) error {
+		s.onResult = fn
+		return nil
+	}
+}
+
+// OnError calls fn for every file that could not be read.
+func OnError(fn func(FileError)) Option {
+	return func(s *Scanner) error {
+		s.onError = fn
+		return nil
+	}
+}
+
+// OnFinding calls fn for every secret found.
+func OnFinding(fn func(Finding)) Option {
+	return func(s *Scanner) error {
+		s.onFinding = fn
+		return nil
+	}
+}
+
+// OnProgress calls fn with the counters of the scan every interval and once
+// more when collection ends.
+func OnProgress(interval time.Duration, fn func(Progress)) Option {
+	return func(s *Scanner) error {
+		if interval <= 0 {
+			return errors.New("progress interval must be positive")
+		}
+		s.onProgress = fn
+		s.progressInterval = interval
+		return nil
+	}
+}
+
+// Run scans until every file is collected or ctx is cancelled and returns the
+// metrics. A cancelled scan returns its partial metrics together with the
+// context's error; its checkpoint, if any, is saved so it can be resumed.
+// Post-processing such as verification only runs for completed scans.
+func (s *Scanner) Run(ctx context.Context) (*ScanMetrics, error) {
+	config := s.config
+
+	s.metricsMutex.Lock()
+	s.metrics.StartTime = time.Now()
+	s.metricsMutex.Unlock()
+
+	if config.Prefilter && s.directoryDuplicates {
+		config.logf("Prefilter disabled: comparing directories needs every file hashed")
+		config.Prefilter = false
+	}
+	if config.Prefilter && config.Baseline != nil {
+		config.logf("Prefilter disabled: checking a manifest needs every file hashed")
+		config.Prefilter = false
+	}
+	if config.Prefilter && config.Simhash {
+		config.logf("Prefilter disabled: finding similar files needs every file read")
+		config.Prefilter = false
+	}
+	if config.Prefilter && config.Denylist != nil {
+		config.logf("Prefilter disabled: matching the denylist needs every file hashed")
+		config.Prefilter = false
+	}
+	if config.Prefilter && config.Secrets != nil {
+		config.logf("Prefilter disabled: secret detection needs every file read")
+		config.Prefilter = false
+	}
+	if config.Prefilter && (len(s.outputs) > 0 || len(config.Sinks) > 0) {
+		config.logf("Prefilter disabled: writing an inventory needs every file hashed")
+		config.Prefilter = false
+	}
+
+	if config.Baseline != nil {
+		config.Baseline.roots = config.Directories
+	}
+
+	//PICK UP AN INTERRUPTED SCAN WHERE ITS LAST CHECKPOINT LEFT OFF
+	resumed := false
+	if s.checkpointPath != "" {
+		config.Checkpoint = NewCheckpoint(s.checkpointPath)
+		if s.resume {
+			var err error
+			s.metricsMutex.Lock()
+			resumed, err = config.Checkpoint.Resume(config, s.metrics)
+			s.metricsMutex.Unlock()
+			if err != nil {
+				return nil, fmt.Errorf("resuming scan: %w", err)
+			}
+			if !resumed {
+				config.logf("No checkpoint at %s, starting a new scan", s.checkpointPath)
+			}
+		}
+	}
+	if resumed {
+		config.logf("Resuming scan: %d files already collected", s.metrics.FilesScanned)
+		if config.Baseline != nil {
+			replayBaseline(config.Baseline, s.metrics)
+		}
+		if config.Prefilter {
+			config.logf("Prefilter disabled: a resumed scan cannot compare sizes with files it skips")
+			config.Prefilter = false
+		}
+	}
+
+	//STREAM EVERY RESULT TO THE OUTPUTS AS IT IS COLLECTED
+	var outputs []ResultSink
+	for _, spec := range s.outputs {
+		sink, err := OpenSink(spec, resumed)
+		if err != nil {
+			closeSinks(outputs)
+			return nil, fmt.Errorf("opening output %s: %w", spec, err)
+		}
+		outputs = append(outputs, sink)
+	}
+	config.Sinks = append(append([]ResultSink(nil), config.Sinks...), outputs...)
+
+	if s.onResult != nil || s.onError != nil || s.onFinding != nil {
+		config.onResult = s.dispatch
+	}
+
+	//INITIALIZE CHANNELS
+	tasksChannel := make(chan FileTask, 100)
+	resultsChannel := make(chan ScanResult, 100)
+
+	discoveryDone := make(chan struct{})
+	go func() {
+		DiscoverFiles(ctx, config, tasksChannel, s.metrics, &s.metricsMutex)
+		close(discoveryDone)
+	}()
+
+	//IN COORDINATOR MODE REMOTE AGENTS TAKE THE PLACE OF LOCAL WORKERS
+	var workersDone <-chan struct{}
+	if s.coordinatorAddr != "" {
+		coordinator := NewCoordinator(ctx, s.coordinatorAddr, config, tasksChannel, resultsChannel)
+		coordinator.Start()
+		defer coordinator.Stop()
+	} else {
+		workersDone = startWorkers(ctx, config, tasksChannel, resultsChannel)
+	}
+
+	//THE COLLECTOR RETURNS ONCE EVERY RESULT IS IN OR, AFTER A CANCEL, ONCE IT DRAINED
+	completed := false
+	collectorDone := make(chan struct{})
+	go func() {
+		CollectResults(ctx, config, resultsChannel, s.metrics, &s.metricsMutex)
+		completed = ctx.Err() == nil
+		close(collectorDone)
+	}()
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	if config.Checkpoint != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			saveCheckpoints(config, s.metrics, &s.metricsMutex, s.checkpointInterval, stop)
+		}()
+	}
+	if s.onProgress != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			s.reportProgress(stop)
+		}()
+	}
+
+	<-collectorDone
+	close(stop)
+
+	//NO STAGE MAY STILL TOUCH THE METRICS, THE CACHE OR THE SINKS ONCE RUN RETURNS
+	background.Wait()
+	<-discoveryDone
+	if workersDone != nil {
+		<-workersDone
+	}
+
+	//CONFIRM DUPLICATES BYTE FOR BYTE INSTEAD OF TRUSTING THE HASH ALONE
+	if s.verify && completed {
+		s.metricsMutex.RLock()
+		candidates := make(map[string][]string)
+		for hash, paths := range s.metrics.Duplicates {
+			if len(paths) >= 2 {
+				candidates[hash] = paths
+			}
+		}
+		s.metricsMutex.RUnlock()
+
+		config.logf("Verifying %d duplicate groups", len(candidates))
+		groups, status := VerifyDuplicates(candidates, config.WorkerCount)
+
+		s.metricsMutex.Lock()
+		applyVerification(s.metrics, groups, status)
+		s.metricsMutex.Unlock()
+	}
+
+	//COLLAPSE COPIED FOLDERS INTO ONE DIRECTORY-LEVEL RESULT
+	if s.directoryDuplicates && completed {
+		s.metricsMutex.Lock()
+		s.metrics.DuplicateDirectories, s.metrics.SubsetDirectories = FindDuplicateDirectories(s.metrics, config.Directories)
+		s.metricsMutex.Unlock()
+	}
+
+	if config.NearDuplicates != nil {
+		s.metricsMutex.Lock()
+		s.metrics.NearDuplicates = config.NearDuplicates.Groups()
+		s.metricsMutex.Unlock()
+	}
+
+	//A FINISHED SCAN HAS NOTHING TO RESUME, AN INTERRUPTED ONE SAVES WHERE IT STOPPED
+	if config.Checkpoint != nil {
+		var err error
+		if completed {
+			err = config.Checkpoint.Remove()
+		} else {
+			s.metricsMutex.RLock()
+			err = config.Checkpoint.Save(config, s.metrics)
+			s.metricsMutex.RUnlock()
+			if err == nil {
+				config.logf("Checkpoint saved to %s, rerun with -resume to continue", s.checkpointPath)
+			}
+		}
+		if err != nil {
+			config.logf("Error updating checkpoint: %v", err)
+		}
+	}
+
+	s.metricsMutex.Lock()
+	s.metrics.EndTime = time.Now()
+	if config.Baseline != nil {
+		report := config.Baseline.Report()
+		s.metrics.Baseline = &report
+	}
+	s.metricsMutex.Unlock()
+	if err := closeSinks(outputs); err != nil {
+		config.logf("Error closing output: %v", err)
+	}
+
+	if !completed {
+		return s.metrics, ctx.Err()
+	}
+	return s.metrics, nil
+}
+
+// View runs fn with read access to the live metrics. fn must not modify them
+// or keep them after it returns.
+func (s *Scanner) View(fn func(metrics *ScanMetrics)) {
+	s.metricsMutex.RLock()
+	defer s.metricsMutex.RUnlock()
+	fn(s.metrics)
+}
+
+// Progress returns the counters of the scan so far.
+func (s *Scanner) Progress() Progress {
+	s.metricsMutex.RLock()
+	defer s.metricsMutex.RUnlock()
+	return Progress{
+		FilesScanned: s.metrics.FilesScanned,
+		FilesPending: s.metrics.FilesPending,
+		TotalBytes:   s.metrics.TotalBytes,
+		Errors:       len(s.metrics.Errors),
+	}
+}
+
+// dispatch hands a collected result to the callbacks interested in it.
+func (s *Scanner) dispatch(result ScanResult) {
+	if s.onResult != nil {
+		s.onResult(result)
+	}
+	if result.Error != "" {
+		if s.onError != nil {
+			s.onError(FileError{Path: result.Path, Error: result.Error, Time: time.Now()})
+		}
+		return
+	}
+	if s.onFinding != nil {
+		for _, finding := range result.Findings {
+			s.onFinding(finding)
+		}
+	}
+}
+
+// reportProgress calls the progress callback every interval and once more
+// when stop is closed.
+func (s *Scanner) reportProgress(stop chan struct{}) {
+	ticker := time.NewTicker(s.progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.onProgress(s.Progress())
+
+		case <-stop:
+			s.onProgress(s.Progress())
+			return
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNew tests the defaults filled in and the configs rejected
+func TestNew(t *testing.T) {
+	s, err := New(ScanConfig{})
+	if err != nil {
+		t.Fatalf("Expected empty config to be valid, got %v", err)
+	}
+	if len(s.config.Directories) != 1 || s.config.Directories[0] != "." {
+		t.Errorf("Expected the current directory by default, got %v", s.config.Directories)
+	}
+	if s.config.WorkerCount <= 0 || s.config.MaxFileSize <= 0 {
+		t.Errorf("Expected workers and max size defaults, got %d and %d", s.config.WorkerCount, s.config.MaxFileSize)
+	}
+	if s.config.HashAlgorithms[0] != DefaultHashAlgorithm || s.config.TypeCountBy != TypeByExtension {
+		t.Errorf("Expected default hash and type count, got %v and %s", s.config.HashAlgorithms, s.config.TypeCountBy)
+	}
+
+	invalid := []struct {
+		name    string
+		config  ScanConfig
+		options []Option
+	}{
+		{"type count", ScanConfig{TypeCountBy: "size"}, nil},
+		{"hash", ScanConfig{HashAlgorithms: []string{"crc32"}}, nil},
+		{"pattern", ScanConfig{Include: []string{"["}}, nil},
+		{"resume without checkpoint", ScanConfig{}, []Option{WithResume()}},
+		{"resume with similarity", ScanConfig{}, []Option{WithCheckpoint("scan.ckpt", time.Second), WithResume(), WithSimilarity(0.9)}},
+		{"checkpoint interval", ScanConfig{}, []Option{WithCheckpoint("scan.ckpt", 0)}},
+		{"similarity", ScanConfig{}, []Option{WithSimilarity(2)}},
+	}
+	for _, tc := range invalid {
+		if _, err := New(tc.config, tc.options...); err == nil {
+			t.Errorf("Expected %s to be rejected", tc.name)
+		}
+	}
+}
+
+// TestScanner_Run tests a scan of a small tree and the callbacks it runs
+func TestScanner_Run(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"a.txt": "same", "b.txt": "same", "c.log": "other"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var results []string
+	var last Progress
+	s, err := New(ScanConfig{Directories: []string{dir}, WorkerCount: 2},
+		OnResult(func(result ScanResult) {
+			mu.Lock()
+			results = append(results, result.Path)
+			mu.Unlock()
+		}),
+		OnProgress(time.Hour, func(progress Progress) {
+			mu.Lock()
+			last = progress
+			mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected scan to complete, got %v", err)
+	}
+	if metrics.FilesScanned != 3 || metrics.TotalBytes != 13 {
+		t.Errorf("Expected 3 files and 13 bytes, got %d and %d", metrics.FilesScanned, metrics.TotalBytes)
+	}
+	if metrics.TypeCount[".txt"] != 2 || metrics.TypeCount[".log"] != 1 {
+		t.Errorf("Expected type counts by extension, got %v", metrics.TypeCount)
+	}
+	if countGroups(metrics) != 1 {
+		t.Errorf("Expected one duplicate group, got %v", metrics.Duplicates)
+	}
+	if metrics.EndTime.Before(metrics.StartTime) {
+		t.Errorf("Expected end time after start time, got %v and %v", metrics.StartTime, metrics.EndTime)
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected a callback per result, got %v", results)
+	}
+	if last.FilesScanned != 3 || last.FilesPending != 0 {
+		t.Errorf("Expected a final progress report, got %+v", last)
+	}
+}
+
+// TestScanner_RunCancelled tests that a cancelled scan returns the context's error
+func TestScanner_RunCancelled(t *testing.T) {
+	s, err := New(ScanConfig{Directories: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	metrics, err := s.Run(ctx)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if metrics == nil {
+		t.Error("Expected the partial metrics of a cancelled scan")
+	}
+}
+
+// TestScanner_RunLogger tests that messages go to the logger of WithLogger
+func TestScanner_RunLogger(t *testing.T) {
+	var logged bytes.Buffer
+	missing := filepath.Join(t.TempDir(), "missing")
+	s, err := New(ScanConfig{Directories: []string{missing}, Prefilter: true},
+		WithDirectoryDuplicates(),
+		WithLogger(log.New(&logged, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Expected scan to complete, got %v", err)
+	}
+	for _, message := range []string{"Prefilter disabled: comparing directories", missing} {
+		if !bytes.Contains(logged.Bytes(), []byte(message)) {
+			t.Errorf("Expected %q to be logged, got %q", message, logged.String())
+		}
+	}
+}
+
+func countGroups(metrics *ScanMetrics) int {
+	count := 0
+	for _, paths := range metrics.Duplicates {
+		if len(paths) > 1 {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,230 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+)
+
+// maxSecretLineLen caps how much of a single line is matched against the
+// rules, so minified files and binaries without newlines stay cheap.
+const maxSecretLineLen = 4096
+
+// SecretRule flags a line when Pattern matches. When MinEntropy is set, the
+// first capture group (or the whole match) must also have at least that much
+// Shannon entropy per character, which filters out placeholders.
+type SecretRule struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Pattern     string  `json:"pattern"`
+	MinEntropy  float64 `json:"min_entropy,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Finding is one rule match in a scanned file.
+type Finding struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	RuleID string `json:"rule_id"`
+	Match  string `json:"match"`
+}
+
+// SecretRuleSet is a compiled list of rules. Fingerprint identifies the rules
+// so cached findings are only reused for the same rule set.
+type SecretRuleSet struct {
+	Rules       []SecretRule
+	Fingerprint string
+}
+
+var DefaultSecretRules = []SecretRule{
+	{
+		ID:          "aws-access-key-id",
+		Description: "AWS access key ID",
+		Pattern:     `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[0-9A-Z]{16}\b`,
+	},
+	{
+		ID:          "aws-secret-access-key",
+		Description: "AWS secret access key",
+		Pattern:     `(?i)aws.{0,20}(?:secret|private).{0,20}[:=]\s*['"]?([A-Za-z0-9/+]{40})\b`,
+		MinEntropy:  3.5,
+	},
+	{
+		ID:          "private-key",
+		Description: "PEM encoded private key",
+		Pattern:     `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`,
+	},
+	{
+		ID:          "github-token",
+		Description: "GitHub personal access, OAuth or app token",
+		Pattern:     `\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`,
+	},
+	{
+		ID:          "generic-high-entropy",
+		Description: "High entropy value assigned to a secret-looking name",
+		Pattern:     `(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|pwd|credential)[\w.-]*['"]?\s*[:=]\s*['"]?([A-Za-z0-9+/=_\-]{20,})`,
+		MinEntropy:  4.0,
+	},
+}
+
+// NewSecretRuleSet compiles the rules and fingerprints them.
+func NewSecretRuleSet(rules []SecretRule) (*SecretRuleSet, error) {
+	compiled := make([]SecretRule, len(rules))
+	fingerprint := sha256.New()
+
+	for i, rule := range rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("secret rule %d has no id", i+1)
+		}
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %s: %w", rule.ID, err)
+		}
+		rule.regex = regex
+		compiled[i] = rule
+		fmt.Fprintf(fingerprint, "%s\x00%s\x00%g\n", rule.ID, rule.Pattern, rule.MinEntropy)
+	}
+
+	return &SecretRuleSet{
+		Rules:       compiled,
+		Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+	}, nil
+}
+
+// LoadSecretRules reads a JSON array of rules from a file.
+func LoadSecretRules(path string) ([]SecretRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []SecretRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing secret rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// secretScanner is an io.Writer that matches the rules line by line as the
+// file is hashed, so detection shares the hashing read pass. It turns itself
+// off when the first chunk looks binary.
+type secretScanner struct {
+	rules    *SecretRuleSet
+	path     string
+	line     int
+	partial  []byte
+	checked  bool
+	binary   bool
+	findings []Finding
+}
+
+func newSecretScanner(path string, rules *SecretRuleSet) *secretScanner {
+	return &secretScanner{rules: rules, path: path}
+}
+
+func (s *secretScanner) Write(p []byte) (int, error) {
+	if !s.checked {
+		s.checked = true
+		sample := p
+		if len(sample) > sniffLen {
+			sample = sample[:sniffLen]
+		}
+		s.binary = bytes.IndexByte(sample, 0) >= 0
+	}
+	if s.binary {
+		return len(p), nil
+	}
+
+	data := p
+	for {
+		newline := bytes.IndexByte(data, '\n')
+		if newline < 0 {
+			s.appendPartial(data)
+			return len(p), nil
+		}
+		s.appendPartial(data[:newline])
+		s.scanLine()
+		data = data[newline+1:]
+	}
+}
+
+// Close scans the last line when the file does not end with a newline.
+func (s *secretScanner) Close() {
+	if !s.binary && len(s.partial) > 0 {
+		s.scanLine()
+	}
+}
+
+func (s *secretScanner) appendPartial(data []byte) {
+	if room := maxSecretLineLen - len(s.partial); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		s.partial = append(s.partial, data...)
+	}
+}
+
+func (s *secretScanner) scanLine() {
+	s.line++
+	line := s.partial
+	s.partial = s.partial[:0]
+
+	for _, rule := range s.rules.Rules {
+		match := rule.regex.FindSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		secret := match[0]
+		if len(match) > 1 && len(match[1]) > 0 {
+			secret = match[1]
+		}
+		if rule.MinEntropy > 0 && shannonEntropy(secret) < rule.MinEntropy {
+			continue
+		}
+
+		s.findings = append(s.findings, Finding{
+			Path:   s.path,
+			Line:   s.line,
+			RuleID: rule.ID,
+			Match:  redactSecret(string(match[0])),
+		})
+	}
+}
+
+// shannonEntropy returns the entropy of data in bits per character.
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	entropy := 0.0
+	length := float64(len(data))
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactSecret keeps just enough of a match to recognise it in a report.
+func redactSecret(match string) string {
+	const visible = 8
+	if len(match) <= visible {
+		return "****"
+	}
+	return match[:visible] + "****"
+}
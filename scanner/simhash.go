@@ -0,0 +1,254 @@
+package scanner
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// Content-defined chunking splits files where a rolling hash hits a boundary,
+// so an edit only changes the chunks around it. Each chunk is one simhash
+// feature.
+const (
+	simhashChunkMask = 1<<5 - 1
+	simhashMinChunk  = 8
+
+	//FILES WITH FEWER CHUNKS THAN THIS GIVE TOO NOISY A FINGERPRINT
+	simhashMinChunks = 8
+)
+
+// MinSimilarity is the lowest similarity threshold accepted. Below it nearly every
+// file becomes a candidate for every other and clustering turns quadratic.
+const MinSimilarity = 0.8
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// simhashGear drives the rolling boundary hash.
+var simhashGear = func() [256]uint64 {
+	var gear [256]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range gear {
+		//SPLITMIX64 KEEPS THE TABLE THE SAME ON EVERY BUILD
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+	return gear
+}()
+
+// simhasher is an io.Writer that builds a 64-bit simhash of content-defined
+// chunks as the file is hashed, so it shares the hashing read pass.
+type simhasher struct {
+	counts   [64]int
+	rolling  uint64
+	chunk    uint64
+	chunkLen int
+	chunks   int
+}
+
+func newSimhasher() *simhasher {
+	return &simhasher{chunk: fnvOffset}
+}
+
+func (s *simhasher) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.rolling = s.rolling<<1 + simhashGear[b]
+		s.chunk = (s.chunk ^ uint64(b)) * fnvPrime
+		s.chunkLen++
+		if s.chunkLen >= simhashMinChunk && s.rolling&simhashChunkMask == 0 {
+			s.addChunk()
+		}
+	}
+	return len(p), nil
+}
+
+func (s *simhasher) addChunk() {
+	for bit := 0; bit < 64; bit++ {
+		if s.chunk&(1<<bit) != 0 {
+			s.counts[bit]++
+		} else {
+			s.counts[bit]--
+		}
+	}
+	s.chunks++
+	s.chunk = fnvOffset
+	s.chunkLen = 0
+}
+
+// Sum returns the simhash as 16 hex digits, or "" when the file was too short.
+func (s *simhasher) Sum() string {
+	if s.chunkLen > 0 {
+		s.addChunk()
+	}
+	if s.chunks < simhashMinChunks {
+		return ""
+	}
+
+	var hash uint64
+	for bit, count := range s.counts {
+		if count > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// simhashSimilarity is the share of the 64 bits two simhashes agree on.
+func simhashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// NearDuplicate is one file of a near-duplicate group with its similarity to
+// the first file of the group.
+type NearDuplicate struct {
+	Path       string  `json:"path"`
+	Similarity float64 `json:"similarity"`
+}
+
+// NearDuplicateGroup is a set of files that are similar without being
+// identical. Files are linked when their similarity reaches the threshold, so
+// a file can be less similar to the first one through a chain of others.
+type NearDuplicateGroup struct {
+	MinSimilarity float64         `json:"min_similarity"`
+	Files         []NearDuplicate `json:"files"`
+}
+
+type simhashEntry struct {
+	path    string
+	simhash uint64
+	parent  int
+}
+
+// NearDuplicateIndex clusters simhashes as the collector receives them.
+// Candidates are found by splitting the 64 bits into one more band than the
+// number of bits that may differ: two simhashes within the threshold must
+// agree on at least one whole band.
+type NearDuplicateIndex struct {
+	maxDistance int
+	bands       []simhashBand
+	buckets     []map[uint64][]int
+	entries     []simhashEntry
+	hashes      map[string]bool
+}
+
+type simhashBand struct {
+	shift uint
+	mask  uint64
+}
+
+// NewNearDuplicateIndex returns an index linking files whose similarity is at
+// least threshold, between MinSimilarity and 1.
+func NewNearDuplicateIndex(threshold float64) (*NearDuplicateIndex, error) {
+	if threshold < MinSimilarity || threshold > 1 {
+		return nil, fmt.Errorf("similarity must be between %g and 1", MinSimilarity)
+	}
+
+	maxDistance := int((1 - threshold) * 64)
+	index := &NearDuplicateIndex{maxDistance: maxDistance, hashes: make(map[string]bool)}
+
+	bandCount := maxDistance + 1
+	start := uint(0)
+	for i := 0; i < bandCount; i++ {
+		width := uint(64 / bandCount)
+		if i < 64%bandCount {
+			width++
+		}
+		index.bands = append(index.bands, simhashBand{shift: start, mask: 1<<width - 1})
+		index.buckets = append(index.buckets, make(map[uint64][]int))
+		start += width
+	}
+	return index, nil
+}
+
+// Add indexes a result and links it to every earlier file within the
+// threshold. Only the first file of each hash is indexed: identical copies are
+// already reported as exact duplicates.
+func (n *NearDuplicateIndex) Add(result ScanResult) {
+	if result.Simhash == "" || n.hashes[result.Hash] {
+		return
+	}
+	simhash, err := strconv.ParseUint(result.Simhash, 16, 64)
+	if err != nil {
+		return
+	}
+
+	n.hashes[result.Hash] = true
+	id := len(n.entries)
+	n.entries = append(n.entries, simhashEntry{path: result.Path, simhash: simhash, parent: id})
+
+	compared := make(map[int]bool)
+	for band := range n.buckets {
+		key := (simhash >> n.bands[band].shift) & n.bands[band].mask
+		for _, other := range n.buckets[band][key] {
+			if compared[other] {
+				continue
+			}
+			compared[other] = true
+			if bits.OnesCount64(simhash^n.entries[other].simhash) <= n.maxDistance {
+				n.union(id, other)
+			}
+		}
+		n.buckets[band][key] = append(n.buckets[band][key], id)
+	}
+}
+
+func (n *NearDuplicateIndex) find(id int) int {
+	for n.entries[id].parent != id {
+		n.entries[id].parent = n.entries[n.entries[id].parent].parent
+		id = n.entries[id].parent
+	}
+	return id
+}
+
+func (n *NearDuplicateIndex) union(a, b int) {
+	rootA, rootB := n.find(a), n.find(b)
+	if rootA == rootB {
+		return
+	}
+	//THE EARLIER FILE STAYS THE ROOT SO GROUPS START WITH THEIR FIRST FILE
+	if rootB < rootA {
+		rootA, rootB = rootB, rootA
+	}
+	n.entries[rootB].parent = rootA
+}
+
+// Groups returns every group of near-duplicates, the largest first.
+func (n *NearDuplicateIndex) Groups() []NearDuplicateGroup {
+	members := make(map[int][]int)
+	for id := range n.entries {
+		root := n.find(id)
+		members[root] = append(members[root], id)
+	}
+
+	var groups []NearDuplicateGroup
+	for root, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+
+		first := n.entries[root]
+		group := NearDuplicateGroup{MinSimilarity: 1}
+		for _, id := range ids {
+			similarity := simhashSimilarity(first.simhash, n.entries[id].simhash)
+			group.Files = append(group.Files, NearDuplicate{Path: n.entries[id].path, Similarity: similarity})
+			if similarity < group.MinSimilarity {
+				group.MinSimilarity = similarity
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Files) != len(groups[j].Files) {
+			return len(groups[i].Files) > len(groups[j].Files)
+		}
+		return groups[i].Files[0].Path < groups[j].Files[0].Path
+	})
+	return groups
+}
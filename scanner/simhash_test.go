@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func simhashOf(data []byte) string {
+	hasher := newSimhasher()
+	hasher.Write(data)
+	return hasher.Sum()
+}
+
+// TestSimhash tests that a small edit keeps most bits and unrelated content does not
+func TestSimhash(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	original := make([]byte, 64*1024)
+	random.Read(original)
+
+	edited := append([]byte(nil), original...)
+	copy(edited[30000:], "a small edit in the middle of the file")
+
+	other := make([]byte, len(original))
+	random.Read(other)
+
+	parse := func(hash string) uint64 {
+		var value uint64
+		fmt.Sscanf(hash, "%x", &value)
+		return value
+	}
+	a, b, c := parse(simhashOf(original)), parse(simhashOf(edited)), parse(simhashOf(other))
+
+	if similarity := simhashSimilarity(a, b); similarity < 0.9 {
+		t.Errorf("Expected an edited file to be at least 0.9 similar, got %.2f", similarity)
+	}
+	if similarity := simhashSimilarity(a, c); similarity >= 0.8 {
+		t.Errorf("Expected unrelated files to be less than 0.8 similar, got %.2f", similarity)
+	}
+
+	if hash := simhashOf([]byte("short")); hash != "" {
+		t.Errorf("Expected no simhash for a short file, got %q", hash)
+	}
+}
+
+// TestNearDuplicateIndex tests clustering by threshold, skipping identical files
+func TestNearDuplicateIndex(t *testing.T) {
+	if _, err := NewNearDuplicateIndex(0.5); err == nil {
+		t.Errorf("Expected an error for a threshold below %g", MinSimilarity)
+	}
+
+	index, err := NewNearDuplicateIndex(0.9)
+	if err != nil {
+		t.Fatalf("NewNearDuplicateIndex failed: %v", err)
+	}
+
+	base := uint64(0xf0f0f0f0f0f0f0f0)
+	results := []ScanResult{
+		{Path: "a.txt", Hash: "h1", Simhash: fmt.Sprintf("%016x", base)},
+		{Path: "b.txt", Hash: "h2", Simhash: fmt.Sprintf("%016x", base^0x7)},
+		{Path: "c.txt", Hash: "h3", Simhash: fmt.Sprintf("%016x", base^0x7<<40)},
+		{Path: "copy-of-a.txt", Hash: "h1", Simhash: fmt.Sprintf("%016x", base)},
+		{Path: "far.txt", Hash: "h4", Simhash: fmt.Sprintf("%016x", ^base)},
+		{Path: "tiny.txt", Hash: "h5"},
+	}
+	for _, result := range results {
+		index.Add(result)
+	}
+
+	groups := index.Groups()
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %+v", groups)
+	}
+
+	var paths []string
+	for _, file := range groups[0].Files {
+		paths = append(paths, file.Path)
+	}
+	if got := strings.Join(paths, ","); got != "a.txt,b.txt,c.txt" {
+		t.Errorf("Expected a.txt,b.txt,c.txt, got %s", got)
+	}
+	if groups[0].Files[0].Similarity != 1 {
+		t.Errorf("Expected the first file to have similarity 1, got %v", groups[0].Files[0].Similarity)
+	}
+	if expected := 1 - 3.0/64; groups[0].MinSimilarity != expected {
+		t.Errorf("Expected minimum similarity %v, got %v", expected, groups[0].MinSimilarity)
+	}
+}
@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats accepted by -output.
+const (
+	OutputJSONL  = "jsonl"
+	OutputCSV    = "csv"
+	OutputSQLite = "sqlite"
+)
+
+// sinkFlushInterval bounds how much buffered output a crash can lose.
+const sinkFlushInterval = time.Second
+
+// ResultSink receives every scan result as the collector sees it. Sinks write
+// as results arrive, so an interrupted scan keeps what was already collected.
+type ResultSink interface {
+	Write(result ScanResult) error
+	Close() error
+}
+
+// errSQLiteUnavailable is returned for SQLite outputs by builds without cgo.
+var errSQLiteUnavailable = errors.New("SQLite output is not available in this build, rebuild with CGO_ENABLED=1 or use JSON Lines or CSV")
+
+// OpenSink creates a sink from an -output value, which is either
+// "format:path" or a path whose extension picks the format (.csv, .db,
+// .sqlite or .sqlite3, and JSON Lines for anything else). With appendOutput,
+// a resumed scan adds to the files written before it was interrupted.
+func OpenSink(spec string, appendOutput bool) (ResultSink, error) {
+	format, path := parseOutputSpec(spec)
+	if path == "" {
+		return nil, fmt.Errorf("output %q has no path", spec)
+	}
+
+	switch format {
+	case OutputJSONL:
+		return newJSONLSink(path, appendOutput)
+	case OutputCSV:
+		return newCSVSink(path, appendOutput)
+	case OutputSQLite:
+		return newSQLiteSink(path)
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+func parseOutputSpec(spec string) (format, path string) {
+	if prefix, rest, ok := strings.Cut(spec, ":"); ok {
+		switch prefix {
+		case OutputJSONL, OutputCSV, OutputSQLite:
+			return prefix, rest
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(spec)) {
+	case ".csv":
+		return OutputCSV, spec
+	case ".db", ".sqlite", ".sqlite3":
+		return OutputSQLite, spec
+	}
+	return OutputJSONL, spec
+}
+
+// bufferedFile flushes its buffer at most every sinkFlushInterval.
+type bufferedFile struct {
+	file      *os.File
+	buffer    *bufio.Writer
+	lastFlush time.Time
+}
+
+func createBufferedFile(path string, appendOutput bool) (*bufferedFile, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendOutput {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedFile{file: file, buffer: bufio.NewWriter(file), lastFlush: time.Now()}, nil
+}
+
+func (f *bufferedFile) maybeFlush() error {
+	if time.Since(f.lastFlush) < sinkFlushInterval {
+		return nil
+	}
+	f.lastFlush = time.Now()
+	return f.buffer.Flush()
+}
+
+func (f *bufferedFile) Close() error {
+	err := f.buffer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// jsonlSink writes one InventoryRecord per line.
+type jsonlSink struct {
+	*bufferedFile
+	encoder *json.Encoder
+}
+
+func newJSONLSink(path string, appendOutput bool) (*jsonlSink, error) {
+	file, err := createBufferedFile(path, appendOutput)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlSink{bufferedFile: file, encoder: json.NewEncoder(file.buffer)}, nil
+}
+
+func (s *jsonlSink) Write(result ScanResult) error {
+	if err := s.encoder.Encode(newInventoryRecord(result)); err != nil {
+		return err
+	}
+	return s.maybeFlush()
+}
+
+// csvSink writes one InventoryRecord per row after a header row.
+type csvSink struct {
+	*bufferedFile
+	writer *csv.Writer
+}
+
+func newCSVSink(path string, appendOutput bool) (*csvSink, error) {
+	file, err := createBufferedFile(path, appendOutput)
+	if err != nil {
+		return nil, err
+	}
+	sink := &csvSink{bufferedFile: file, writer: csv.NewWriter(file.buffer)}
+
+	//AN APPENDED FILE ALREADY HAS ITS HEADER
+	if info, err := file.file.Stat(); err == nil && info.Size() > 0 {
+		return sink, nil
+	}
+	if err := sink.writer.Write(inventoryHeader); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *csvSink) Write(result ScanResult) error {
+	record := newInventoryRecord(result)
+	modTime := ""
+	if !record.ModTime.IsZero() {
+		modTime = record.ModTime.Format(time.RFC3339Nano)
+	}
+
+	err := s.writer.Write([]string{
+		record.Path,
+		strconv.FormatInt(record.Size, 10),
+		modTime,
+		record.Mode,
+		record.Hash,
+		record.Type,
+		record.Error,
+	})
+	if err != nil {
+		return err
+	}
+
+	if time.Since(s.lastFlush) < sinkFlushInterval {
+		return nil
+	}
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	return s.maybeFlush()
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	err := s.writer.Error()
+	if closeErr := s.bufferedFile.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// closeSinks flushes every -output sink and returns the errors of the ones
+// that fail.
+func closeSinks(sinks []ResultSink) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+//go:build cgo
+
+package scanner
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteAvailable tells whether this build can write SQLite outputs, which
+// needs cgo.
+const sqliteAvailable = true
+
+// sqliteBatchSize is how many rows are committed per transaction.
+const sqliteBatchSize = 1000
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	path     TEXT PRIMARY KEY,
+	size     INTEGER NOT NULL,
+	mtime    TEXT,
+	mode     TEXT,
+	hash     TEXT,
+	hashes   TEXT,
+	type     TEXT,
+	mime     TEXT,
+	archive  TEXT,
+	error    TEXT
+);
+CREATE INDEX IF NOT EXISTS results_hash ON results (hash);
+CREATE TABLE IF NOT EXISTS findings (
+	path     TEXT NOT NULL,
+	line     INTEGER NOT NULL,
+	rule_id  TEXT NOT NULL,
+	match    TEXT
+);
+`
+
+// sqliteSink stores results in a SQLite database, committing every
+// sqliteBatchSize rows or sinkFlushInterval, whichever comes first. Rescanning
+// into the same database replaces the rows of files seen again.
+type sqliteSink struct {
+	db        *sql.DB
+	tx        *sql.Tx
+	rows      int
+	lastFlush time.Time
+}
+
+func newSQLiteSink(path string) (*sqliteSink, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteSink{db: db, lastFlush: time.Now()}, nil
+}
+
+func (s *sqliteSink) Write(result ScanResult) error {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		s.tx = tx
+	}
+
+	var modTime, hashes any
+	if !result.ModTime.IsZero() {
+		modTime = result.ModTime.Format(time.RFC3339Nano)
+	}
+	if len(result.Hashes) > 0 {
+		encoded, _ := json.Marshal(result.Hashes)
+		hashes = string(encoded)
+	}
+
+	_, err := s.tx.Exec(
+		`INSERT OR REPLACE INTO results (path, size, mtime, mode, hash, hashes, type, mime, archive, error)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.Path, result.Size, modTime, result.Mode.String(), result.Hash, hashes,
+		result.FileType, result.MimeType, result.Archive, result.Error,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.tx.Exec(`DELETE FROM findings WHERE path = ?`, result.Path); err != nil {
+		return err
+	}
+	for _, finding := range result.Findings {
+		_, err := s.tx.Exec(
+			`INSERT INTO findings (path, line, rule_id, match) VALUES (?, ?, ?, ?)`,
+			finding.Path, finding.Line, finding.RuleID, finding.Match,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.rows++
+	if s.rows >= sqliteBatchSize || time.Since(s.lastFlush) >= sinkFlushInterval {
+		return s.commit()
+	}
+	return nil
+}
+
+func (s *sqliteSink) commit() error {
+	s.rows = 0
+	s.lastFlush = time.Now()
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Commit()
+	s.tx = nil
+	return err
+}
+
+func (s *sqliteSink) Close() error {
+	err := s.commit()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package scanner
+
+// sqliteAvailable tells whether this build can write SQLite outputs, which
+// needs cgo.
+const sqliteAvailable = false
+
+func newSQLiteSink(path string) (ResultSink, error) {
+	return nil, errSQLiteUnavailable
+}
@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseOutputSpec tests explicit formats and formats picked by extension
+func TestParseOutputSpec(t *testing.T) {
+	tests := []struct {
+		spec, format, path string
+	}{
+		{"results.jsonl", OutputJSONL, "results.jsonl"},
+		{"results.CSV", OutputCSV, "results.CSV"},
+		{"scan.db", OutputSQLite, "scan.db"},
+		{"csv:/tmp/out.txt", OutputCSV, "/tmp/out.txt"},
+		{"sqlite:scan.data", OutputSQLite, "scan.data"},
+		{"C:/scans/out.csv", OutputCSV, "C:/scans/out.csv"},
+	}
+
+	for _, test := range tests {
+		format, path := parseOutputSpec(test.spec)
+		if format != test.format || path != test.path {
+			t.Errorf("parseOutputSpec(%q) = %q, %q; expected %q, %q", test.spec, format, path, test.format, test.path)
+		}
+	}
+}
+
+// TestInventory_RoundTrip tests writing and reading JSON Lines and CSV inventories
+func TestInventory_RoundTrip(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	results := []ScanResult{
+		{Path: "/srv/a.txt", Size: 5, ModTime: modTime, Mode: 0644, Hash: "aaa", FileType: ".txt"},
+		{Path: "/srv/b,c.txt", Size: 5, ModTime: modTime, Mode: 0600, Hash: "aaa", FileType: ".txt"},
+		{Path: "/srv/locked.bin", Error: "permission denied"},
+	}
+
+	for _, name := range []string{"inventory.jsonl", "inventory.csv"} {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), name)
+			sink, err := OpenSink(fileName, false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, result := range results {
+				if err := sink.Write(result); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var records []InventoryRecord
+			err = LoadInventory(fileName, func(record InventoryRecord) {
+				records = append(records, record)
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(records) != 3 {
+				t.Fatalf("Expected 3 records, got %d", len(records))
+			}
+			if records[1].Path != "/srv/b,c.txt" || records[1].Mode != "-rw-------" || !records[1].ModTime.Equal(modTime) {
+				t.Errorf("Unexpected record: %+v", records[1])
+			}
+			if records[2].Error != "permission denied" || records[2].Hash != "" {
+				t.Errorf("Expected error record without hash, got %+v", records[2])
+			}
+		})
+	}
+}
+
+// TestCollectResults_Sinks tests that the collector streams every result
+func TestCollectResults_Sinks(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "inventory.csv")
+	sink, err := OpenSink(fileName, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resultsChannel := make(chan ScanResult, 3)
+	resultsChannel <- ScanResult{Path: "/srv/a.txt", Size: 1, Hash: "aaa", FileType: ".txt"}
+	resultsChannel <- ScanResult{Path: "/srv/b.txt", Size: 1, Hash: "bbb", FileType: ".txt"}
+	resultsChannel <- ScanResult{Path: "/srv/c.txt", Error: "gone"}
+	close(resultsChannel)
+
+	metrics := &ScanMetrics{TotalFiles: 3}
+	CollectResults(context.Background(), ScanConfig{Sinks: []ResultSink{sink}}, resultsChannel, metrics, &sync.RWMutex{})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(fileName)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || lines[0] != "path,size,mtime,mode,hash,type,error" {
+		t.Errorf("Expected header and 3 rows, got:\n%s", data)
+	}
+}
+
+// TestLoadScanResults_Inventory tests that diff sees unique files in an
+// inventory, which is recognized by its content rather than its extension
+func TestLoadScanResults_Inventory(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "inventory.json")
+	os.WriteFile(fileName, []byte(
+		`{"path":"/srv/a.txt","size":3,"hash":"aaa","type":".txt"}`+"\n"+
+			`{"path":"/srv/b.txt","size":4,"hash":"bbb","type":".txt"}`+"\n",
+	), 0644)
+
+	metrics, err := LoadScanResults(fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metrics.TotalFiles != 2 || metrics.TotalBytes != 7 || metrics.TypeCount[".txt"] != 2 {
+		t.Errorf("Unexpected totals: %+v", metrics)
+	}
+
+	diff := DiffScans(ScanMetrics{Inventory: map[string]InventoryRecord{}}, metrics)
+	if len(diff.Added) != 2 || len(diff.NewDuplicateGroups) != 0 {
+		t.Errorf("Expected 2 added files and no duplicate groups, got %+v", diff)
+	}
+}
+
+// TestScanner_RunOutputWithPrefilter tests that a file with a unique size is
+// still hashed in the inventory when the prefilter is on
+func TestScanner_RunOutputWithPrefilter(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "unique.txt"), []byte("only one of these"), 0644)
+	fileName := filepath.Join(t.TempDir(), "inventory.jsonl")
+
+	s, err := New(ScanConfig{Directories: []string{dir}, Prefilter: true}, WithOutputs(fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Expected scan to complete, got %v", err)
+	}
+
+	var records []InventoryRecord
+	if err := LoadInventory(fileName, func(record InventoryRecord) { records = append(records, record) }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].Hash == "" {
+		t.Errorf("Expected unique.txt to be hashed, got %+v", records)
+	}
+}
+
+// TestSQLiteSink tests that results and findings land in the database
+func TestSQLiteSink(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "scan.db")
+	sink, err := OpenSink(fileName, false)
+	if err != nil {
+		t.Skipf("SQLite unavailable: %v", err)
+	}
+
+	results := []ScanResult{
+		{Path: "/srv/a.txt", Size: 5, Hash: "aaa", Hashes: map[string]string{"sha256": "aaa"}, FileType: ".txt"},
+		{Path: "/srv/b.txt", Size: 5, Hash: "aaa", FileType: ".txt", Findings: []Finding{{Path: "/srv/b.txt", Line: 2, RuleID: "github-token"}}},
+		{Path: "/srv/a.txt", Size: 6, Hash: "ccc", FileType: ".txt"},
+	}
+	for _, result := range results {
+		if err := sink.Write(result); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", fileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	var hash string
+	db.QueryRow(`SELECT COUNT(*) FROM results`).Scan(&count)
+	db.QueryRow(`SELECT hash FROM results WHERE path = '/srv/a.txt'`).Scan(&hash)
+	if count != 2 || hash != "ccc" {
+		t.Errorf("Expected 2 rows with the rescanned hash, got %d rows and %q", count, hash)
+	}
+
+	db.QueryRow(`SELECT COUNT(*) FROM findings WHERE rule_id = 'github-token'`).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 finding, got %d", count)
+	}
+}
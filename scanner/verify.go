@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Verification status of a duplicate group.
+const (
+	VerifyVerified   = "verified"
+	VerifySplit      = "split"
+	VerifyUnverified = "unverified"
+)
+
+// splitGroupSeparator joins a hash and a number into the key of a group split
+// off a hash collision, e.g. "9f86d0...#2".
+const splitGroupSeparator = "#"
+
+// VerifyDuplicates compares the files of every duplicate group byte for byte,
+// spreading the groups over workerCount goroutines. A group whose files are
+// not all identical is split: the first set of identical files keeps the hash
+// as its key and the others get "hash#2", "hash#3" and so on. It returns the
+// verified groups and the status of each, keyed the same way. Groups holding
+// archive entries or files that cannot be read stay as they are, unverified.
+func VerifyDuplicates(duplicates map[string][]string, workerCount int) (map[string][]string, map[string]string) {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	hashes := make(chan string)
+	groups := make(map[string][]string)
+	status := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hash := range hashes {
+				classes, err := splitIdentical(duplicates[hash])
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					groups[hash] = duplicates[hash]
+					status[hash] = VerifyUnverified
+				case len(classes) == 1:
+					groups[hash] = classes[0]
+					status[hash] = VerifyVerified
+				default:
+					for i, class := range classes {
+						key := hash
+						if i > 0 {
+							key = fmt.Sprintf("%s%s%d", hash, splitGroupSeparator, i+1)
+						}
+						groups[key] = class
+						status[key] = VerifySplit
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for hash, paths := range duplicates {
+		if len(paths) >= 2 {
+			hashes <- hash
+		}
+	}
+	close(hashes)
+	wg.Wait()
+
+	return groups, status
+}
+
+// splitIdentical sorts paths into sets of byte for byte identical files, in
+// the order they were first seen.
+func splitIdentical(paths []string) ([][]string, error) {
+	for _, path := range paths {
+		if strings.Contains(path, archiveEntrySeparator) {
+			return nil, fmt.Errorf("%s is inside an archive", path)
+		}
+	}
+
+	var classes [][]string
+	for _, path := range paths {
+		placed := false
+		for i, class := range classes {
+			same, err := sameContent(class[0], path)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				classes[i] = append(class, path)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			classes = append(classes, []string{path})
+		}
+	}
+	return classes, nil
+}
+
+// applyVerification replaces the duplicate groups of metrics with verified
+// ones. The caller must hold the metrics mutex.
+func applyVerification(metrics *ScanMetrics, groups map[string][]string, status map[string]string) {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		metrics.Duplicates[key] = groups[key]
+		hash, _, split := strings.Cut(key, splitGroupSeparator)
+		if digests, ok := metrics.Hashes[hash]; split && ok {
+			metrics.Hashes[key] = digests
+		}
+	}
+	metrics.Verification = status
+}
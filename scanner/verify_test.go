@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerifyDuplicates tests that identical groups are verified and groups
+// hiding different content are split
+func TestVerifyDuplicates(t *testing.T) {
+	tempDir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(tempDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+
+	same := []string{write("a1", "alpha"), write("a2", "alpha")}
+	collided := []string{write("b1", "bravo"), write("c1", "charl"), write("b2", "bravo"), write("c2", "charl"), write("d1", "delta")}
+	archived := []string{filepath.Join(tempDir, "app.jar") + archiveEntrySeparator + "x", write("x", "x")}
+
+	duplicates := map[string][]string{
+		"aaa":    same,
+		"bbb":    collided,
+		"zzz":    archived,
+		"single": {write("s", "single")},
+	}
+
+	groups, status := VerifyDuplicates(duplicates, 3)
+
+	if status["aaa"] != VerifyVerified || len(groups["aaa"]) != 2 {
+		t.Errorf("Expected aaa verified, got %s %v", status["aaa"], groups["aaa"])
+	}
+	if status["zzz"] != VerifyUnverified || len(groups["zzz"]) != 2 {
+		t.Errorf("Expected group with archive entry unverified, got %s %v", status["zzz"], groups["zzz"])
+	}
+	if _, ok := groups["single"]; ok {
+		t.Error("Expected single files not to be verified")
+	}
+
+	if len(groups["bbb"]) != 2 || groups["bbb"][1] != collided[2] {
+		t.Errorf("Expected bbb to keep the first identical set, got %v", groups["bbb"])
+	}
+	if len(groups["bbb#2"]) != 2 || groups["bbb#2"][0] != collided[1] {
+		t.Errorf("Expected bbb#2 to hold the c files, got %v", groups["bbb#2"])
+	}
+	if len(groups["bbb#3"]) != 1 || status["bbb#3"] != VerifySplit {
+		t.Errorf("Expected d1 alone in bbb#3, got %s %v", status["bbb#3"], groups["bbb#3"])
+	}
+
+	metrics := &ScanMetrics{
+		Duplicates: duplicates,
+		Hashes:     map[string]map[string]string{"bbb": {"sha256": "bbb", "md5": "b"}},
+	}
+	applyVerification(metrics, groups, status)
+	real := CollectRealMetrics(metrics)
+	if _, ok := real.Duplicates["bbb#3"]; ok {
+		t.Error("Expected a group split down to one file not to be reported")
+	}
+	if real.Verification["bbb#2"] != VerifySplit || real.Hashes["bbb#2"]["md5"] != "b" {
+		t.Errorf("Expected split group status and digests in the output, got %v %v", real.Verification, real.Hashes)
+	}
+	if real.DuplicateFilesCount != 4 {
+		t.Errorf("Expected 4 duplicate files after splitting, got %d", real.DuplicateFilesCount)
+	}
+}
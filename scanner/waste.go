@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultTopN is how many groups and directories the waste reports list.
+const DefaultTopN = 10
+
+// Orders accepted by /duplicates?sort=.
+const (
+	SortByWaste  = "waste"
+	SortByCopies = "copies"
+	SortBySize   = "size"
+)
+
+// DuplicateGroupWaste is the disk space one duplicate group wastes. Every
+// on-disk copy beyond the first is reclaimable; archive entries are listed
+// but never counted, since removing them would change the archive.
+type DuplicateGroupWaste struct {
+	Hash        string   `json:"hash"`
+	Size        int64    `json:"size"`
+	Copies      int      `json:"copies"`
+	Reclaimable int64    `json:"reclaimable_bytes"`
+	Paths       []string `json:"paths"`
+}
+
+// DirectoryWaste is the reclaimable space of the extra copies in one directory.
+type DirectoryWaste struct {
+	Directory   string `json:"directory"`
+	Files       int    `json:"files"`
+	Reclaimable int64  `json:"reclaimable_bytes"`
+}
+
+// groupSize returns the file size of a duplicate group. Groups split by
+// -verify share the size of the hash they were split from.
+func groupSize(metrics *ScanMetrics, key string) int64 {
+	hash, _, _ := strings.Cut(key, splitGroupSeparator)
+	return metrics.Sizes[hash]
+}
+
+// DuplicateGroupsByWaste returns every duplicate group with the space it
+// wastes, the most wasteful first.
+func DuplicateGroupsByWaste(metrics *ScanMetrics) []DuplicateGroupWaste {
+	var groups []DuplicateGroupWaste
+	for hash, paths := range metrics.Duplicates {
+		if len(paths) < 2 {
+			continue
+		}
+
+		group := DuplicateGroupWaste{
+			Hash:  hash,
+			Size:  groupSize(metrics, hash),
+			Paths: paths,
+		}
+		for _, path := range paths {
+			if !strings.Contains(path, archiveEntrySeparator) {
+				group.Copies++
+			}
+		}
+		if group.Copies > 1 {
+			group.Reclaimable = group.Size * int64(group.Copies-1)
+		}
+		groups = append(groups, group)
+	}
+
+	SortDuplicateGroups(groups, SortByWaste)
+	return groups
+}
+
+// SortDuplicateGroups orders groups by reclaimable bytes, number of paths or
+// file size, largest first, breaking ties by hash.
+func SortDuplicateGroups(groups []DuplicateGroupWaste, order string) {
+	key := func(group DuplicateGroupWaste) int64 {
+		switch order {
+		case SortByCopies:
+			return int64(len(group.Paths))
+		case SortBySize:
+			return group.Size
+		}
+		return group.Reclaimable
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if a, b := key(groups[i]), key(groups[j]); a != b {
+			return a > b
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+}
+
+// DirectoriesByWaste adds up the reclaimable copies of every group by the
+// directory they are in, the most wasteful first. The copy with the shortest
+// path is treated as the one to keep, matching dedupe's default.
+func DirectoriesByWaste(groups []DuplicateGroupWaste) []DirectoryWaste {
+	byDirectory := make(map[string]*DirectoryWaste)
+	for _, group := range groups {
+		if group.Reclaimable == 0 {
+			continue
+		}
+
+		var onDisk []string
+		for _, path := range group.Paths {
+			if !strings.Contains(path, archiveEntrySeparator) {
+				onDisk = append(onDisk, path)
+			}
+		}
+		sort.Slice(onDisk, func(i, j int) bool {
+			if len(onDisk[i]) != len(onDisk[j]) {
+				return len(onDisk[i]) < len(onDisk[j])
+			}
+			return onDisk[i] < onDisk[j]
+		})
+
+		for _, path := range onDisk[1:] {
+			dir := filepath.Dir(path)
+			waste, ok := byDirectory[dir]
+			if !ok {
+				waste = &DirectoryWaste{Directory: dir}
+				byDirectory[dir] = waste
+			}
+			waste.Files++
+			waste.Reclaimable += group.Size
+		}
+	}
+
+	directories := make([]DirectoryWaste, 0, len(byDirectory))
+	for _, waste := range byDirectory {
+		directories = append(directories, *waste)
+	}
+	sort.Slice(directories, func(i, j int) bool {
+		if directories[i].Reclaimable != directories[j].Reclaimable {
+			return directories[i].Reclaimable > directories[j].Reclaimable
+		}
+		return directories[i].Directory < directories[j].Directory
+	})
+	return directories
+}
+
+// ReclaimableBytes totals the reclaimable bytes of every group.
+func ReclaimableBytes(groups []DuplicateGroupWaste) int64 {
+	var total int64
+	for _, group := range groups {
+		total += group.Reclaimable
+	}
+	return total
+}
+
+// TopN returns the first limit items, or all of them when limit is not positive.
+func TopN[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
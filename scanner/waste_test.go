@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"testing"
+)
+
+func wasteMetrics() *ScanMetrics {
+	return &ScanMetrics{
+		Duplicates: map[string][]string{
+			"big":    {"/srv/a/big.iso", "/srv/backup/big.iso"},
+			"small":  {"/srv/a/x.txt", "/srv/backup/x.txt", "/srv/backup/old/x.txt", "/srv/app.jar!/x.txt"},
+			"nested": {"/srv/a.jar!/lib.so", "/srv/b.jar!/lib.so"},
+			"unique": {"/srv/a/unique.bin"},
+		},
+		Sizes: map[string]int64{"big": 1000, "small": 10, "nested": 500, "unique": 99},
+	}
+}
+
+// TestDuplicateGroupsByWaste tests reclaimable bytes per group and directory
+func TestDuplicateGroupsByWaste(t *testing.T) {
+	groups := DuplicateGroupsByWaste(wasteMetrics())
+
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 duplicate groups, got %d", len(groups))
+	}
+	if groups[0].Hash != "big" || groups[0].Reclaimable != 1000 {
+		t.Errorf("Expected big first with 1000 bytes, got %+v", groups[0])
+	}
+	if groups[1].Hash != "small" || groups[1].Copies != 3 || groups[1].Reclaimable != 20 {
+		t.Errorf("Expected archive entry not to count as a copy, got %+v", groups[1])
+	}
+	if groups[2].Reclaimable != 0 {
+		t.Errorf("Expected archive-only group to reclaim nothing, got %+v", groups[2])
+	}
+	if total := ReclaimableBytes(groups); total != 1020 {
+		t.Errorf("Expected 1020 reclaimable bytes, got %d", total)
+	}
+
+	directories := DirectoriesByWaste(groups)
+	if len(directories) != 2 || directories[0].Directory != "/srv/backup" || directories[0].Reclaimable != 1010 || directories[0].Files != 2 {
+		t.Errorf("Expected /srv/backup to waste 1010 bytes in 2 files, got %+v", directories)
+	}
+
+	SortDuplicateGroups(groups, SortByCopies)
+	if groups[0].Hash != "small" {
+		t.Errorf("Expected most copies first, got %s", groups[0].Hash)
+	}
+
+	real := CollectRealMetrics(wasteMetrics())
+	if real.ReclaimableBytes != 1020 || real.Reclaimable["small"] != 20 || len(real.TopWaste) != 3 {
+		t.Errorf("Expected waste in the saved metrics, got %d %v", real.ReclaimableBytes, real.Reclaimable)
+	}
+	if _, ok := real.Sizes["unique"]; ok {
+		t.Error("Expected sizes of unique files to be left out")
+	}
+}
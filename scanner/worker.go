@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WorkerProcessFiles hashes tasks until taskChannel is closed or ctx is
+// cancelled. A cancelled worker stops before its next task; the file it was
+// hashing is dropped unless the collector is still receiving.
+func WorkerProcessFiles(ctx context.Context, id int, config ScanConfig, taskChannel chan FileTask, resultsChannel chan ScanResult) {
+
+	for {
+		select {
+		case task, ok := <-taskChannel:
+			if !ok {
+				return
+			}
+			for _, result := range taskResults(task, config) {
+				select {
+				case resultsChannel <- result:
+
+				case <-ctx.Done():
+					return
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+
+	}
+}
+
+// taskResults returns one result per entry when the task is an archive and
+// archive expansion is on, followed by the result for the task itself. The
+// archive comes last so a checkpoint only counts it as collected, and skips it
+// on resume, once every entry has been collected.
+func taskResults(task FileTask, config ScanConfig) []ScanResult {
+	results := ExpandArchive(task, config)
+	return append(results, processTask(task, config))
+}
+
+// processTask answers a task from the hash cache when the file is unchanged
+// since the last scan and hashes it otherwise.
+func processTask(task FileTask, config ScanConfig) ScanResult {
+	if config.HashCache == nil {
+		return ProcessFiles(task, config)
+	}
+
+	if cached, ok := config.HashCache.Lookup(task, config); ok {
+		cached.FileType = typeKey(cached, config)
+		return cached
+	}
+
+	result := ProcessFiles(task, config)
+	config.HashCache.Store(task, result, config)
+	return result
+}
+
+func ProcessFiles(task FileTask, config ScanConfig) ScanResult {
+	result := ScanResult{
+		Path:      task.Path,
+		Size:      task.Size,
+		ModTime:   task.ModTime,
+		Mode:      task.Mode,
+		Extension: filepath.Ext(task.Path),
+		FileType:  filepath.Ext(task.Path),
+	}
+
+	//NOTHING TO COMPARE AGAINST, SO THE FILE IS ONLY READ WHEN ITS TYPE IS NEEDED
+	if task.SkipHash {
+		if config.TypeCountBy == TypeByMime {
+			sniffFileType(task.Path, &result, config)
+		}
+		return result
+	}
+
+	//GET FILE
+	file, err := os.Open(task.Path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	defer file.Close()
+
+	err = hashContent(file, &result, config)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	return result
+
+}
+
+// hashContent hashes content with every configured algorithm, and its simhash
+// when enabled, and sniffs its type in one read pass, filling in the result's
+// digests and types. It is shared by files on disk and archive entries.
+func hashContent(content io.Reader, result *ScanResult, config ScanConfig) error {
+	hashers, primary, err := newHashers(config.HashAlgorithms)
+	if err != nil {
+		return err
+	}
+
+	header := &headerCapture{}
+	writers := make([]io.Writer, 0, len(hashers)+3)
+	writers = append(writers, header)
+
+	var secrets *secretScanner
+	if config.Secrets != nil {
+		secrets = newSecretScanner(result.Path, config.Secrets)
+		writers = append(writers, secrets)
+	}
+
+	var simhash *simhasher
+	if config.Simhash {
+		simhash = newSimhasher()
+		writers = append(writers, simhash)
+	}
+
+	for _, hasher := range hashers {
+		writers = append(writers, hasher)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), content)
+	if err != nil {
+		return err
+	}
+
+	result.Hashes = make(map[string]string, len(hashers))
+	for name, hasher := range hashers {
+		result.Hashes[name] = hex.EncodeToString(hasher.Sum(nil))
+	}
+	result.Hash = result.Hashes[primary]
+
+	if simhash != nil {
+		result.Simhash = simhash.Sum()
+	}
+
+	if secrets != nil {
+		secrets.Close()
+		result.Findings = secrets.findings
+	}
+
+	result.MimeType = DetectMimeType(header.data)
+	result.FileType = typeKey(*result, config)
+	return nil
+}
+
+// sniffFileType reads just the header of a file that is not being hashed.
+func sniffFileType(path string, result *ScanResult, config ScanConfig) {
+	file, err := os.Open(path)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		result.Error = err.Error()
+		return
+	}
+
+	result.MimeType = DetectMimeType(header[:n])
+	result.FileType = typeKey(*result, config)
+}
+
+// startWorkers runs the local worker pool and closes the results channel once
+// every worker has returned. The returned channel is closed at the same time.
+func startWorkers(ctx context.Context, config ScanConfig, tasksChannel chan FileTask, resultsChannel chan ScanResult) <-chan struct{} {
+	var workerWaitGroup sync.WaitGroup
+	workerWaitGroup.Add(config.WorkerCount)
+
+	for i := 0; i < config.WorkerCount; i++ {
+		go func(id int) {
+			WorkerProcessFiles(ctx, id, config, tasksChannel, resultsChannel)
+			workerWaitGroup.Done()
+
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		workerWaitGroup.Wait()
+		close(resultsChannel)
+		close(done)
+	}()
+	return done
+}
@@ -1,6 +1,7 @@
-package main
+package scanner
 
 import (
+	"crypto/md5"
 	"crypto/sha256"
 	"encoding/hex"
 	"os"
@@ -27,7 +28,7 @@ func TestProcessFile_Success(t *testing.T) {
 	}
 
 	// Execute
-	result := ProcessFiles(task)
+	result := ProcessFiles(task, ScanConfig{})
 
 	// Assert: Check result
 	if result.Path != testFile {
@@ -63,7 +64,7 @@ func TestProcessFile_NonExistent(t *testing.T) {
 		Size: 100,
 	}
 
-	result := ProcessFiles(task)
+	result := ProcessFiles(task, ScanConfig{})
 
 	// Should have an error
 	if result.Error == "" {
@@ -92,7 +93,7 @@ func TestProcessFile_EmptyFile(t *testing.T) {
 		Size: 0,
 	}
 
-	result := ProcessFiles(task)
+	result := ProcessFiles(task, ScanConfig{})
 
 	// Should succeed
 	if result.Error != "" {
@@ -123,7 +124,7 @@ func TestProcessFile_NoExtension(t *testing.T) {
 		Size: 12,
 	}
 
-	result := ProcessFiles(task)
+	result := ProcessFiles(task, ScanConfig{})
 
 	if result.Error != "" {
 		t.Errorf("Unexpected error: %s", result.Error)
@@ -159,7 +160,7 @@ func TestProcessFile_DifferentTypes(t *testing.T) {
 			}
 
 			task := FileTask{Path: testFile, Size: 12}
-			result := ProcessFiles(task)
+			result := ProcessFiles(task, ScanConfig{})
 
 			if result.FileType != tt.wantType {
 				t.Errorf("Expected file type %s, got %s", tt.wantType, result.FileType)
@@ -167,3 +168,50 @@ func TestProcessFile_DifferentTypes(t *testing.T) {
 		})
 	}
 }
+
+// TestProcessFile_MultipleAlgorithms tests producing several digests in one pass
+func TestProcessFile_MultipleAlgorithms(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "vendor.bin")
+	testContent := []byte("vendor manifest content")
+
+	if err := os.WriteFile(testFile, testContent, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := ScanConfig{HashAlgorithms: []string{"md5", "sha256", "blake3", "xxhash"}}
+	result := ProcessFiles(FileTask{Path: testFile, Size: int64(len(testContent))}, config)
+
+	if result.Error != "" {
+		t.Fatalf("Unexpected error: %s", result.Error)
+	}
+
+	expectedMD5 := md5.Sum(testContent)
+	expectedSHA := sha256.Sum256(testContent)
+
+	// The first algorithm is the primary hash
+	if result.Hash != hex.EncodeToString(expectedMD5[:]) {
+		t.Errorf("Expected primary md5 hash, got %s", result.Hash)
+	}
+	if result.Hashes["sha256"] != hex.EncodeToString(expectedSHA[:]) {
+		t.Errorf("Expected sha256 %x, got %s", expectedSHA, result.Hashes["sha256"])
+	}
+	if len(result.Hashes) != 4 {
+		t.Errorf("Expected 4 digests, got %d", len(result.Hashes))
+	}
+}
+
+// TestParseHashAlgorithms tests the -hash flag parsing
+func TestParseHashAlgorithms(t *testing.T) {
+	algorithms, err := ParseHashAlgorithms(" SHA256, md5,sha256 ")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(algorithms) != 2 || algorithms[0] != "sha256" || algorithms[1] != "md5" {
+		t.Errorf("Expected [sha256 md5], got %v", algorithms)
+	}
+
+	if _, err := ParseHashAlgorithms("crc7"); err == nil {
+		t.Error("Expected error for unknown algorithm")
+	}
+}
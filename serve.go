@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// runServe implements "serve [flags]" and returns the exit code. It runs scan
+// jobs submitted over HTTP until the process is interrupted, starting with a
+// scan of -dir or the configured roots if there are any.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var f scanFlags
+	f.register(flags)
+	jobsFlag := flags.Int("jobs", 1, "Number of scan jobs run at the same time")
+	historyFlag := flags.Int("history", 100, "Number of finished scan jobs kept for /scans")
+	var allowRoots stringListFlag
+	flags.Var(&allowRoots, "allow-root", "Directory submitted scans may cover, with everything below it, repeatable (defaults to -dir or the configured roots)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s serve [flags]\n\nRun scans submitted to POST /scans until interrupted. The scan flags are the defaults of every job.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if errs := f.applyConfig(flags); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return exitUsage
+	}
+
+	//EVERY JOB RUNS ON ITS OWN, SO NOTHING STATEFUL MAY BE SHARED BETWEEN THEM
+	if f.mode != "local" || f.httpAddr == "" {
+		fmt.Println("Error: serve needs -http and only runs local scans")
+		return exitUsage
+	}
+	if f.checkpoint != "" || f.resume || len(f.outputs) > 0 || f.manifest != "" || f.writeManifest != "" {
+		fmt.Println("Error: -checkpoint, -resume, -output, -manifest and -write-manifest only apply to scan")
+		return exitUsage
+	}
+	//ANY CLIENT OF THE API CAN SUBMIT SCANS, SO IT MAY ONLY REACH WHAT IS ALLOWED
+	if len(allowRoots) == 0 {
+		allowRoots = f.dirs
+	}
+	if len(allowRoots) == 0 {
+		fmt.Println("Error: serve needs -allow-root, -dir or configured roots to limit what scans may cover")
+		return exitUsage
+	}
+	if *jobsFlag <= 0 || *historyFlag < 0 {
+		fmt.Println("Error: -jobs must be positive and -history not negative")
+		return exitUsage
+	}
+
+	config, code := f.scanConfig()
+	if code != exitOK {
+		return code
+	}
+	defer closeHashCache(config.HashCache)
+
+	queue := NewJobQueue(config, f.options(), allowRoots, *historyFlag)
+	if len(config.Directories) > 0 {
+		status, err := queue.Submit(JobRequest{})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return exitUsage
+		}
+		fmt.Printf("Scan %s queued: %v\n", status.ID, status.Roots)
+	} else if _, err := scanner.New(config, f.options()...); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	server := NewJobServer(queue, f.httpAddr)
+	server.Start()
+	queue.Run(ctx, *jobsFlag)
+	server.Stop()
+	return exitOK
+}
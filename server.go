@@ -3,12 +3,21 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
+
+	"Distributed_Artifact_Scanner/scanner"
 )
 
+// metricsView gives read access to the live metrics of a scan. It is
+// implemented by *scanner.Scanner.
+type metricsView interface {
+	View(fn func(metrics *scanner.ScanMetrics))
+}
+
 type Response struct {
 	FilesScanned int   `json:"files_scanned"`
 	FilesPending int   `json:"files_pending"`
@@ -18,18 +27,21 @@ type Response struct {
 }
 
 type Server struct {
-	metrics      *ScanMetrics
-	metricsMutex *sync.RWMutex
-	doneChannel  chan struct{}
-	httpServer   *http.Server
+	scan       metricsView
+	ctx        context.Context
+	cancel     context.CancelFunc
+	jobs       *JobQueue
+	httpServer *http.Server
 }
 
-func NewServer(metrics *ScanMetrics, doneChannel chan struct{}, metricsMutex *sync.RWMutex) *Server {
+// NewServer serves the progress of the scan running under ctx on addr. POST
+// /cancel calls cancel.
+func NewServer(ctx context.Context, cancel context.CancelFunc, scan metricsView, addr string) *Server {
 	//CREATE NEW SERVER OBJECT
 	server := &Server{
-		metrics:      metrics,
-		doneChannel:  doneChannel,
-		metricsMutex: metricsMutex,
+		scan:   scan,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 
 	mux := http.NewServeMux()
@@ -38,10 +50,34 @@ func NewServer(metrics *ScanMetrics, doneChannel chan struct{}, metricsMutex *sy
 	mux.HandleFunc("/status", server.handleStatus)
 	mux.HandleFunc("/metrics", server.handleMetrics)
 	mux.HandleFunc("/cancel", server.handleCancel)
+	mux.HandleFunc("/findings", server.handleFindings)
+	mux.HandleFunc("/duplicates", server.handleDuplicates)
 
 	//ADD HTTP SERVER INSTANCE
 	server.httpServer = &http.Server{
-		Addr:    ":8080",
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	return server
+}
+
+// NewJobServer serves the scan jobs of queue on addr: POST /scans submits one,
+// /scans/{id}/status, /scans/{id}/metrics, /scans/{id}/findings,
+// /scans/{id}/duplicates and /scans/{id}/cancel act on one.
+func NewJobServer(queue *JobQueue, addr string) *Server {
+	server := &Server{jobs: queue}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scans", server.handleScans)
+	mux.HandleFunc("/scans/{id}/status", server.handleScanStatus)
+	mux.HandleFunc("/scans/{id}/metrics", server.handleScanMetrics)
+	mux.HandleFunc("/scans/{id}/findings", server.handleFindings)
+	mux.HandleFunc("/scans/{id}/duplicates", server.handleDuplicates)
+	mux.HandleFunc("/scans/{id}/cancel", server.handleScanCancel)
+
+	server.httpServer = &http.Server{
+		Addr:    addr,
 		Handler: mux,
 	}
 
@@ -50,7 +86,7 @@ func NewServer(metrics *ScanMetrics, doneChannel chan struct{}, metricsMutex *sy
 
 func (s *Server) Start() {
 	go func() {
-		fmt.Println("HTTP server starting on :8080")
+		fmt.Printf("HTTP server starting on %s\n", s.httpServer.Addr)
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			fmt.Printf("HTTP server error: %v\n", err)
 		}
@@ -76,16 +112,16 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//MUTEX LOCK
-	s.metricsMutex.RLock()
-	response := Response{
-		FilesScanned: s.metrics.FilesScanned,
-		FilesPending: s.metrics.FilesPending,
-		TotalBytes:   s.metrics.TotalBytes,
-		ErrorsCount:  len(s.metrics.Errors),
-		Running:      s.metrics.EndTime.IsZero(),
-	}
-	s.metricsMutex.RUnlock()
+	var response Response
+	s.scan.View(func(metrics *scanner.ScanMetrics) {
+		response = Response{
+			FilesScanned: metrics.FilesScanned,
+			FilesPending: metrics.FilesPending,
+			TotalBytes:   metrics.TotalBytes,
+			ErrorsCount:  len(metrics.Errors),
+			Running:      metrics.EndTime.IsZero(),
+		}
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -98,11 +134,11 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.metricsMutex.RLock()
-
 	//GATHER ACTUAL DUPLICATES... NORMAL METRICS SAVES BOTH DUPLICATES AND NON DUPLICATES
-	metricsCopy := CollectRealMetrics(s.metrics)
-	s.metricsMutex.RUnlock()
+	var metricsCopy scanner.ScanMetrics
+	s.scan.View(func(metrics *scanner.ScanMetrics) {
+		metricsCopy = scanner.CollectRealMetrics(metrics)
+	})
 
 	//RETURN DATA
 	w.Header().Set("Content-Type", "application/json")
@@ -116,14 +152,199 @@ func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try to send cancellation signal
-	select {
-	case s.doneChannel <- struct{}{}:
+	// Cancelling twice is harmless, the second call only reports it
+	if s.ctx.Err() != nil {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Scan cancellation initiated\n"))
+		w.Write([]byte("Scan already stopped\n"))
+		return
+	}
+	s.cancel()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Scan cancellation initiated\n"))
+}
+
+// view returns the metrics a request is about: those of the scan, or in serve
+// mode those of the job in its path. It answers 404 for an unknown job.
+func (s *Server) view(w http.ResponseWriter, r *http.Request) (metricsView, bool) {
+	if s.jobs == nil {
+		return s.scan, true
+	}
+	view, ok := s.jobs.View(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Scan not found", http.StatusNotFound)
+	}
+	return view, ok
+}
+
+// handleFindings returns the secret findings so far, optionally filtered by ?rule=
+func (s *Server) handleFindings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	view, ok := s.view(w, r)
+	if !ok {
+		return
+	}
+	ruleID := r.URL.Query().Get("rule")
+
+	findings := make([]scanner.Finding, 0)
+	view.View(func(metrics *scanner.ScanMetrics) {
+		for _, finding := range metrics.Findings {
+			if ruleID == "" || finding.RuleID == ruleID {
+				findings = append(findings, finding)
+			}
+		}
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(findings)
+}
+
+// DuplicatesResponse lists duplicate groups with the space they waste.
+type DuplicatesResponse struct {
+	ReclaimableBytes int64                         `json:"reclaimable_bytes"`
+	Groups           []scanner.DuplicateGroupWaste `json:"groups"`
+}
+
+// handleDuplicates returns duplicate groups ordered by ?sort=waste (default),
+// copies or size, limited to ?limit=N groups
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	order := r.URL.Query().Get("sort")
+	switch order {
+	case "":
+		order = scanner.SortByWaste
+	case scanner.SortByWaste, scanner.SortByCopies, scanner.SortBySize:
+	default:
+		http.Error(w, "sort must be waste, copies or size", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	view, ok := s.view(w, r)
+	if !ok {
+		return
+	}
+	var groups []scanner.DuplicateGroupWaste
+	view.View(func(metrics *scanner.ScanMetrics) {
+		groups = scanner.DuplicateGroupsByWaste(metrics)
+	})
+
+	scanner.SortDuplicateGroups(groups, order)
+	response := DuplicatesResponse{
+		ReclaimableBytes: scanner.ReclaimableBytes(groups),
+		Groups:           scanner.TopN(groups, limit),
+	}
+	if response.Groups == nil {
+		response.Groups = []scanner.DuplicateGroupWaste{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleScans lists every job, newest first, on GET and submits a JobRequest
+// on POST
+func (s *Server) handleScans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.jobs.List())
+
+	case http.MethodPost:
+		var request JobRequest
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid scan request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		status, err := s.jobs.Submit(request)
+		if errors.Is(err, errQueueFull) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid scan request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/scans/"+status.ID+"/status")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(status)
+
 	default:
-		// Channel already closed or full
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleScanStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, ok := s.jobs.Status(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Scan not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleScanMetrics returns the metrics of a job like /metrics, live while
+// it runs and saved once it finished
+func (s *Server) handleScanMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	metrics, ok := s.jobs.Metrics(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Scan not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// handleScanCancel stops a running job or takes a queued one off the queue
+func (s *Server) handleScanCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	found, stopped := s.jobs.Cancel(r.PathValue("id"))
+	switch {
+	case !found:
+		http.Error(w, "Scan not found", http.StatusNotFound)
+	case !stopped:
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Scan already stopped\n"))
+	default:
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Scan cancellation initiated\n"))
 	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// staticView serves fixed metrics in place of a running scan.
+type staticView struct {
+	metrics *scanner.ScanMetrics
+}
+
+func (v staticView) View(fn func(metrics *scanner.ScanMetrics)) {
+	fn(v.metrics)
+}
+
+func wasteMetrics() *scanner.ScanMetrics {
+	return &scanner.ScanMetrics{
+		Duplicates: map[string][]string{
+			"big":    {"/srv/a/big.iso", "/srv/backup/big.iso"},
+			"small":  {"/srv/a/x.txt", "/srv/backup/x.txt", "/srv/backup/old/x.txt", "/srv/app.jar!/x.txt"},
+			"nested": {"/srv/a.jar!/lib.so", "/srv/b.jar!/lib.so"},
+			"unique": {"/srv/a/unique.bin"},
+		},
+		Sizes: map[string]int64{"big": 1000, "small": 10, "nested": 500, "unique": 99},
+	}
+}
+
+// TestHandleDuplicates tests sorting and limiting the /duplicates endpoint
+func TestHandleDuplicates(t *testing.T) {
+	server := NewServer(context.Background(), func() {}, staticView{wasteMetrics()}, ":8080")
+
+	recorder := httptest.NewRecorder()
+	server.handleDuplicates(recorder, httptest.NewRequest(http.MethodGet, "/duplicates?sort=waste&limit=1", nil))
+
+	var response DuplicatesResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.ReclaimableBytes != 1020 || len(response.Groups) != 1 || response.Groups[0].Hash != "big" {
+		t.Errorf("Expected the big group and 1020 bytes, got %+v", response)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.handleDuplicates(recorder, httptest.NewRequest(http.MethodGet, "/duplicates?sort=name", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown sort, got %d", recorder.Code)
+	}
+}
+
+// TestJobServer tests submitting and looking up jobs over HTTP
+func TestJobServer(t *testing.T) {
+	dir := t.TempDir()
+	server := NewJobServer(NewJobQueue(scanner.ScanConfig{}, nil, []string{dir}, 10), "127.0.0.1:8080")
+	handler := server.httpServer.Handler
+
+	tests := []struct {
+		method   string
+		target   string
+		body     string
+		expected int
+	}{
+		{http.MethodPost, "/scans", `{"roots": ["` + dir + `"]}`, http.StatusAccepted},
+		{http.MethodPost, "/scans", `{"roots": ["` + t.TempDir() + `"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/scans", `{"roots": ["` + dir + `"], "workers": -1}`, http.StatusBadRequest},
+		{http.MethodPost, "/scans", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/scans", `{"rootz": ["/srv"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/scans", `{"roots": ["` + dir + `"], "hash": ["crc"]}`, http.StatusBadRequest},
+		{http.MethodGet, "/scans/1/status", "", http.StatusOK},
+		{http.MethodGet, "/scans/2/status", "", http.StatusNotFound},
+		{http.MethodGet, "/scans/2/metrics", "", http.StatusNotFound},
+		{http.MethodGet, "/scans/1/findings", "", http.StatusOK},
+		{http.MethodGet, "/scans/1/duplicates?sort=size", "", http.StatusOK},
+		{http.MethodGet, "/scans/2/duplicates", "", http.StatusNotFound},
+		{http.MethodPost, "/scans/1/cancel", "", http.StatusOK},
+	}
+	for _, tc := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		if recorder.Code != tc.expected {
+			t.Errorf("Expected %d for %s %s, got %d: %s", tc.expected, tc.method, tc.target, recorder.Code, recorder.Body)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/scans", nil))
+	var jobs []JobStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&jobs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "1" || jobs[0].State != JobCancelled {
+		t.Errorf("Expected the cancelled job, got %+v", jobs)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"Distributed_Artifact_Scanner/scanner"
+)
+
+// runVerify implements "verify [flags] MANIFEST" and returns the exit code.
+// It is a scan that only hashes with the algorithms of the manifest and only
+// reports how the tree differs from it.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var f scanFlags
+	f.registerWalk(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s verify [flags] MANIFEST\n\nCheck directories against a manifest written by scan -write-manifest or sha256sum.\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	if errs := f.applyConfig(flags); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return exitUsage
+	}
+	f.manifest = flags.Arg(0)
+	if len(f.dirs) == 0 {
+		f.dirs = stringListFlag{"."}
+	}
+
+	config, code := f.scanConfig()
+	if code != exitOK {
+		return code
+	}
+	defer closeHashCache(config.HashCache)
+
+	s, err := scanner.New(config, scanner.WithLogger(stdoutLogger))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return exitUsage
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	metrics, err := s.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("Error: %v\n", err)
+		return exitError
+	}
+
+	baseline := metrics.Baseline
+	printPaths("NEW", baseline.New)
+	printPaths("MISSING", baseline.Missing)
+	printPaths("CHANGED", baseline.Changed)
+	printPaths("UNREADABLE", baseline.Unreadable)
+	fmt.Printf("Baseline: %d of %d matched, %d new, %d missing, %d changed\n",
+		baseline.Matched, baseline.Expected, len(baseline.New), len(baseline.Missing), len(baseline.Changed))
+
+	if err != nil {
+		fmt.Println("Verification interrupted before every file was checked")
+		return exitIncomplete
+	}
+	if !baseline.Clean() {
+		return exitBaselineMismatch
+	}
+	return exitOK
+}